@@ -3,6 +3,7 @@ package main
 import (
 	blt "bearlibterminal"
 	"bearrogue/camera"
+	"bearrogue/dungeon"
 	"bearrogue/ecs"
 	"bearrogue/examinecursor"
 	"bearrogue/fov"
@@ -10,7 +11,9 @@ import (
 	"bearrogue/ui"
 	"fmt"
 	"math/rand"
+	"os"
 	"strconv"
+	"time"
 )
 
 const (
@@ -26,11 +29,59 @@ const (
 	PlayerTurn   = iota
 	MobTurn      = iota
 	MapLayer     = 0
-	ActorLayer   = 2
 	ItemLayer    = 3
 	ExamineLayer = 4
 )
 
+const (
+	// Traps are drawn on the map layer, so they appear underneath anything standing on them
+	TrapLayer = MapLayer
+
+	// Where the hand designed vaults are loaded from, and how many may be placed on a single level
+	VaultDir          = "vaults"
+	MaxVaultsPerLevel = 3
+
+	// The percent chance of each doorway into a room getting a door, and of an out of the way room being locked
+	DoorChance       = 60
+	LockedRoomChance = 30
+
+	// How far the player can see, and the field of view algorithm used to work out what they can see ("shadowcast" or
+	// "raycast"). Outside of their torch radius, the player can only see areas that are lit by something else, out to
+	// their sight radius.
+	TorchRadius  = 6
+	SightRadius  = 20
+	FOVAlgorithm = "shadowcast"
+
+	// The percent chance of a room having a brazier burning in it
+	BrazierChance = 25
+
+	// The color the parts of the map revealed by magic mapping are drawn in, until they have been seen for real
+	MappedColor = "darker violet"
+
+	// The color items sensed by magic are drawn in, until they have been seen for real
+	DetectedColor = "light magenta"
+
+	// How many tiles the camera moves with each key press, while looking around the map
+	PanStep = 5
+
+	// The size of the minimap at the bottom of the sidebar, and the color that marks the part of the level on screen
+	MinimapWidth     = 20
+	MinimapHeight    = 10
+	MinimapViewColor = "darkest gray"
+
+	// How many messages are kept in the message history, and where the record of the game is written when it ends. Each
+	// game gets its own morgue file, named after the time it ended.
+	MessageHistoryLength = 1000
+	MorgueFilePattern    = "morgue-%s.txt"
+)
+
+const (
+	// What the targeting cursor is currently being used for
+	TargetThrow = iota
+	TargetAttackOrder
+	TargetUseItem
+)
+
 var (
 	version           string
 	buildStamp        string
@@ -38,6 +89,7 @@ var (
 	player            *ecs.GameEntity
 	entities          []*ecs.GameEntity
 	gameMap           *gamemap.Map
+	gameDungeon       *dungeon.Dungeon
 	gameCamera        *camera.GameCamera
 	fieldOfView       *fov.FieldOfVision
 	gameTurn          int
@@ -48,7 +100,23 @@ var (
 	inInventory       bool
 	informationScreen bool
 	dropping          bool
+	throwing          bool
+	targeting         bool
+	targetingMode     int
+	thrownItem        *ecs.GameEntity
+	usedItem          *ecs.GameEntity
+	using             bool
+	ordering          bool
+	closing           bool
+	freeLook          bool
+	overview          bool
+	history           bool
+	historyScroll     int
+	historyFilter     string
+	historySearching  bool
+	turnCount         int
 	inventoryKeys     map[int]bool
+	vaults            []*gamemap.Vault
 )
 
 func init() {
@@ -78,23 +146,35 @@ func init() {
 	player.AddComponent("player", ecs.PlayerComponent{})
 	player.AddComponent("position", ecs.PositionComponent{X: 0, Y: 0})
 	player.AddComponent("appearance", ecs.AppearanceComponent{Color: "white", Character: "@", Layer: 1, Name: "Player"})
-	player.AddComponent("movement", ecs.MovementComponent{})
+	player.AddComponent("movement", ecs.MovementComponent{CanOpenDoors: true})
 	player.AddComponent("controllable", ecs.ControllableComponent{})
 	player.AddComponent("attacker", ecs.AttackerComponent{Attack: 5, Defense: 5})
 	player.AddComponent("hitpoints", ecs.HitPointComponent{Hp: 20, MaxHP: 20})
 	player.AddComponent("block", ecs.BlockingComponent{})
 	player.AddComponent("killable", ecs.KillableComponent{Name: "Here lies", Character: "%", Color: "dark red"})
 	player.AddComponent("inventory", ecs.InventoryComponent{Capacity: 32})
+	player.AddComponent("faction", ecs.FactionComponent{Name: "player"})
 
 	entities = append(entities, player)
 
-	// Create a GameMap, and initialize it (and set the player position within it, for now)
-	gameMap = &gamemap.Map{Width: MapWidth, Height: MapHeight}
-	gameMap.InitializeMap()
+	// Load the hand designed vaults, to be placed into generated levels. The game can carry on without them.
+	var err error
+	vaults, err = gamemap.LoadVaults(VaultDir)
+	if err != nil {
+		fmt.Printf("Could not load vaults: %v\n", err)
+	}
+
+	// Create the dungeon, and its first level. The player starts out on the stairs leading back up to the surface.
+	gameDungeon = &dungeon.Dungeon{}
+	gameDungeon.AddLevel(generateLevel(1))
 
-	playerX, playerY, mapEntities := GenerateAndPopulateCavern()
+	level := gameDungeon.ChangeLevel(0, entities, nil)
+	gameMap = level.Map
+	entities = level.Entities
 
 	if player.HasComponent("position") {
+		playerX, playerY := arrivalPoint(gameMap, gamemap.StairsUp)
+
 		positionComponent, _ := player.Components["position"].(ecs.PositionComponent)
 		positionComponent.X = playerX
 		positionComponent.Y = playerY
@@ -102,8 +182,6 @@ func init() {
 		player.AddComponent("position", positionComponent)
 	}
 
-	entities = append(entities, mapEntities...)
-
 	// Set the current turn to the player, so they may act first
 	gameTurn = PlayerTurn
 
@@ -111,12 +189,14 @@ func init() {
 	gameCamera = &camera.GameCamera{X: 1, Y: 1, Width: ViewAreaX, Height: ViewAreaY}
 
 	// Initialize a FoV object
-	fieldOfView = &fov.FieldOfVision{}
+	fieldOfView = &fov.FieldOfVision{Algorithm: fov.CasterByName(FOVAlgorithm)}
 	fieldOfView.Initialize()
-	fieldOfView.SetTorchRadius(6)
+	fieldOfView.SetTorchRadius(TorchRadius)
+	fieldOfView.SetSightRadius(SightRadius)
 
 	// Set up the messageLog, and output a "welcome" message
-	messageLog = ui.MessageLog{MaxLength: 100}
+	turnCount = 1
+	messageLog = ui.MessageLog{MaxLength: MessageHistoryLength, Turn: turnCount}
 	messageLog.InitMessages()
 
 	// Set the default examining state to false, which means movement is normal. If this is true, only the examinecursor will
@@ -129,6 +209,11 @@ func init() {
 	inInventory = false
 	informationScreen = false
 	dropping = false
+	throwing = false
+	targeting = false
+	using = false
+	ordering = false
+	closing = false
 
 	inventoryKeys = map[int]bool{blt.TK_A: false,
 		blt.TK_B: false,
@@ -164,7 +249,7 @@ func main() {
 
 	renderSideBar()
 
-	messageLog.SendMessage("You find yourself in the caverns of eternal sadness...you start to feel a little more sad.")
+	messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You find yourself in the caverns of eternal sadness...you start to feel a little more sad.")
 	renderMap()
 	ecs.SystemRender(entities, gameCamera, gameMap)
 	messageLog.PrintMessages(ViewAreaY, WindowSizeX, WindowSizeY)
@@ -185,27 +270,53 @@ func main() {
 		}
 
 		if !inMenu {
-			// Clear each Entity off the screen
-			ecs.SystemClear(entities, gameCamera)
+			turnEnded := false
 
 			if gameTurn == MobTurn {
-				var newEntities []*ecs.GameEntity
-				for _, e := range entities {
-					if e != nil {
-						if !e.HasComponent("player") {
-							ecs.SystemMovement(e, 0, 0, entities, gameMap, &messageLog)
-							newEntities = append(newEntities, ecs.SystemReproduce(e, entities, gameMap, &messageLog))
+				// Everything that comes into (or goes out of) view from here on, until the next turn, happened this turn
+				gameMap.Visibility.StartTurn()
+
+				// The player might notice a hidden trap close by, without needing to search for it
+				ecs.SystemSearch(player, ecs.PassiveSearchRadius, ecs.PassiveSearchChance, entities, gameMap, &messageLog)
+				ecs.SystemTelepathy(player, &messageLog)
+				ecs.SystemTunnel(player, entities, gameMap, &messageLog)
+
+				// If the player is wading through difficult terrain, everything else gets to act again while they
+				// struggle on
+				for takeTurn := true; takeTurn; takeTurn = ecs.SystemSlowed(player) {
+					var newEntities []*ecs.GameEntity
+					for _, e := range entities {
+						if e != nil {
+							if !e.HasComponent("player") {
+								ecs.SystemMovement(e, 0, 0, entities, gameMap, gameCamera, &messageLog)
+								newEntities = append(newEntities, ecs.SystemReproduce(e, entities, gameMap, &messageLog))
+							}
 						}
 					}
+					entities = append(entities, newEntities...)
 				}
-				entities = append(entities, newEntities...)
 				gameTurn = PlayerTurn
+
+				turnCount++
+				messageLog.Turn = turnCount
+				turnEnded = true
 			}
 
+			// Clear each Entity off the screen. This is done after the monsters have acted, so anything they animated
+			// (like projectiles) was drawn over the previous frame, rather than a half cleared one
+			ecs.SystemClear(entities, gameCamera)
+
 			renderMap()
 			ecs.SystemRender(entities, gameCamera, gameMap)
 
-			if examining {
+			if turnEnded {
+				ecs.SystemSpotting(player, entities, gameMap, &messageLog)
+			}
+
+			if examining || targeting {
+				if targeting && targetingMode == TargetThrow {
+					renderLineOfFire(ecs.ThrowingRange(thrownItem))
+				}
 				examineCursor.Draw(gameCamera)
 			} else {
 				// Only print messages if the player is not examining
@@ -221,12 +332,54 @@ func main() {
 			if dropping {
 				renderDroppingScreen()
 			}
+
+			if throwing {
+				renderInventory("Throw which Item?")
+			}
+
+			if using {
+				renderInventory("Use which Item?")
+			}
+
+			if ordering {
+				ui.ClearScreen(WindowSizeX, WindowSizeX)
+				ui.DisplayOrdersMenu(len(ecs.GetFollowers(player, entities)))
+			}
+
+			if overview {
+				renderOverview()
+			}
+
+			if history {
+				historyScroll = messageLog.DisplayHistory(historyScroll, historyFilter, historySearching, WindowSizeX, WindowSizeY)
+			}
 		}
 	}
 
+	writeMorgue()
 	blt.Close()
 }
 
+func writeMorgue() {
+	// Write a record of the game to the morgue file: who the player was, how far they got, and everything that happened
+	// along the way
+	file, err := os.Create(fmt.Sprintf(MorgueFilePattern, time.Now().Format("20060102-150405")))
+	if err != nil {
+		fmt.Printf("Could not write morgue file: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	app, _ := player.Components["appearance"].(ecs.AppearanceComponent)
+
+	fmt.Fprintf(file, "%s, reached depth %d, in %d turns\n\n", app.Name, gameDungeon.CurrentLevel().Depth, turnCount)
+	fmt.Fprintf(file, "Message history:\n\n")
+
+	if err := messageLog.WriteHistory(file); err != nil {
+		fmt.Printf("Could not write morgue file: %v\n", err)
+	}
+}
+
 func handleInput(key int, entity *ecs.GameEntity) {
 	// Handle basic character movement in the four main directions, plus diagonals (and vim keys)
 
@@ -265,14 +418,113 @@ func handleInput(key int, entity *ecs.GameEntity) {
 			} else {
 				examineCursor.Clear(gameCamera)
 			}
+		case blt.TK_Z:
+			// Toggle sneaking. A sneaking player is harder for unaware monsters to spot. Changing stance does not cost
+			// an action.
+			actionTaken = false
+			if player.HasComponent("sneaking") {
+				player.RemoveComponent("sneaking")
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You stop sneaking.")
+			} else {
+				player.RemoveComponent("running")
+				player.AddComponent("sneaking", ecs.SneakingComponent{})
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You begin to move quietly.")
+			}
+		case blt.TK_R:
+			// Toggle running. Every step a running player takes is loud enough to wake anything nearby, which is handy
+			// for drawing monsters out. Changing stance does not cost an action.
+			actionTaken = false
+			if player.HasComponent("running") {
+				player.RemoveComponent("running")
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You slow down to a walk.")
+			} else {
+				player.RemoveComponent("sneaking")
+				player.AddComponent("running", ecs.RunningComponent{})
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You break into a noisy run.")
+			}
 		case blt.TK_COMMA:
-			inventoryKeys = ecs.SystemPickupItem(player, entities, gameCamera, &messageLog, inventoryKeys)
+			// '<' is shift and comma, so check for shift before picking anything up
+			if blt.State(blt.TK_SHIFT) > 0 {
+				actionTaken = useStairs(gamemap.StairsUp)
+			} else {
+				inventoryKeys = ecs.SystemPickupItem(player, entities, gameCamera, &messageLog, inventoryKeys)
+			}
+		case blt.TK_PERIOD:
+			// '>' is shift and period
+			if blt.State(blt.TK_SHIFT) > 0 {
+				actionTaken = useStairs(gamemap.StairsDown)
+			}
+		case blt.TK_V:
+			// Free look - pan the camera around the parts of the map that have been explored, without moving
+			actionTaken = false
+			freeLook = !freeLook
+		case blt.TK_P:
+			// Show the full history of messages
+			actionTaken = false
+			inMenu = true
+			history = true
+			historyScroll = 0
+			historyFilter = ""
+		case blt.TK_M:
+			// Show an overview of the whole level
+			actionTaken = false
+			inMenu = true
+			overview = true
 		case blt.TK_I:
 			inMenu = true
 			inInventory = true
 		case blt.TK_D:
 			inMenu = true
 			dropping = true
+		case blt.TK_T:
+			inMenu = true
+			throwing = true
+		case blt.TK_A:
+			inMenu = true
+			using = true
+		case blt.TK_O:
+			inMenu = true
+			ordering = true
+		case blt.TK_S:
+			// Spend a turn searching the area around the player for hidden traps
+			if ecs.SystemSearch(player, ecs.SearchRadius, ecs.SearchChance, entities, gameMap, &messageLog) == 0 {
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You search the area carefully, but find nothing.")
+			}
+		case blt.TK_C:
+			// Close a door. If there is only one open door nearby, it is closed straight away, otherwise the player is
+			// asked which one they mean.
+			actionTaken = false
+			pos, _ := player.Components["position"].(ecs.PositionComponent)
+			doors := ecs.AdjacentOpenDoors(pos.X, pos.Y, gameMap)
+
+			if len(doors) == 0 {
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "There is no open door nearby.")
+			} else if len(doors) == 1 {
+				actionTaken = ecs.SystemCloseDoor(player, pos.X+doors[0][0], pos.Y+doors[0][1], entities, gameMap, &messageLog)
+			} else {
+				closing = true
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "Close the door in which direction?")
+			}
+		case blt.TK_RETURN, blt.TK_F:
+			// Confirm the location under the targeting cursor
+			if targeting {
+				targeting = false
+				examineCursor.Clear(gameCamera)
+
+				switch targetingMode {
+				case TargetThrow:
+					ecs.SystemThrowItem(player, thrownItem, examineCursor.X, examineCursor.Y, entities, gameMap, gameCamera, &messageLog)
+					thrownItem = nil
+				case TargetUseItem:
+					entities = append(entities, ecs.SystemUseItemAt(player, usedItem, examineCursor.X, examineCursor.Y, entities, gameMap, &messageLog)...)
+					usedItem = nil
+				case TargetAttackOrder:
+					// Giving orders does not take up a turn
+					actionTaken = false
+					target := ecs.GetBlockingEntitiesAtLocation(entities, examineCursor.X, examineCursor.Y)
+					ecs.SystemOrderFollowers(player, ecs.OrderAttack, target, entities, &messageLog)
+				}
+			}
 		case blt.TK_ESCAPE:
 			// Cancel the current action and return the game state to normal
 			actionTaken = false
@@ -281,12 +533,23 @@ func handleInput(key int, entity *ecs.GameEntity) {
 			inInventory = false
 			informationScreen = false
 			dropping = false
+			throwing = false
+			targeting = false
+			thrownItem = nil
+			usedItem = nil
+			using = false
+			ordering = false
+			closing = false
 			if examineCursor != nil {
 				examineCursor.Clear(gameCamera)
 			}
 			ui.ClearScreen(WindowSizeX, WindowSizeX)
 		}
 	} else {
+		if history {
+			handleHistoryInput(key)
+			return
+		}
 
 		selectedEntity := ecs.FindItemWithKey(player, key)
 
@@ -304,6 +567,18 @@ func handleInput(key int, entity *ecs.GameEntity) {
 			} else if dropping {
 				inMenu = false
 				dropping = false
+			} else if throwing {
+				inMenu = false
+				throwing = false
+			} else if using {
+				inMenu = false
+				using = false
+			} else if ordering {
+				inMenu = false
+				ordering = false
+			} else if overview {
+				inMenu = false
+				overview = false
 			}
 
 			if examineCursor != nil {
@@ -312,7 +587,31 @@ func handleInput(key int, entity *ecs.GameEntity) {
 			ui.ClearScreen(WindowSizeX, WindowSizeX)
 		}
 
-		if selectedEntity != nil {
+		if overview {
+			// Any key closes the overview
+			actionTaken = false
+			inMenu = false
+			overview = false
+			ui.ClearScreen(WindowSizeX, WindowSizeX)
+		} else if ordering {
+			// Orders are given to every ally at once. Attacking needs a target, so hand over to the targeting cursor
+			// for that one.
+			actionTaken = false
+			switch key {
+			case blt.TK_F:
+				ecs.SystemOrderFollowers(player, ecs.OrderFollow, nil, entities, &messageLog)
+			case blt.TK_S:
+				ecs.SystemOrderFollowers(player, ecs.OrderStay, nil, entities, &messageLog)
+			case blt.TK_A:
+				startTargeting(TargetAttackOrder)
+			}
+
+			if key == blt.TK_F || key == blt.TK_S || key == blt.TK_A {
+				inMenu = false
+				ordering = false
+				ui.ClearScreen(WindowSizeX, WindowSizeX)
+			}
+		} else if selectedEntity != nil {
 			if inInventory {
 				informationScreen = true
 				renderInformationScreen(selectedEntity)
@@ -321,38 +620,312 @@ func handleInput(key int, entity *ecs.GameEntity) {
 				inMenu = false
 				dropping = false
 				ui.ClearScreen(WindowSizeX, WindowSizeX)
+			} else if throwing {
+				// An item to throw has been chosen, now the player needs to pick where to throw it
+				actionTaken = false
+				thrownItem = selectedEntity
+				inMenu = false
+				throwing = false
+				ui.ClearScreen(WindowSizeX, WindowSizeX)
+				startTargeting(TargetThrow)
+			} else if using {
+				inMenu = false
+				using = false
+				ui.ClearScreen(WindowSizeX, WindowSizeX)
+
+				if ecs.ItemNeedsTarget(selectedEntity) {
+					// Some items need to be aimed, so hand over to the targeting cursor
+					actionTaken = false
+					usedItem = selectedEntity
+					startTargeting(TargetUseItem)
+				} else {
+					entities = append(entities, ecs.SystemUseItem(player, selectedEntity, entities, gameMap, &messageLog)...)
+				}
 			}
 
 		}
 	}
 
-	if examining {
+	if freeLook && dx == 0 && dy == 0 && key != blt.TK_V {
+		// Doing anything other than looking around snaps the camera back to the player
+		freeLook = false
+	}
+
+	if examining || targeting {
 		// Fire off examinecursor movement
 		examine(dx, dy)
+	} else if freeLook {
+		actionTaken = false
+		panCamera(dx*PanStep, dy*PanStep)
+	} else if closing {
+		// A direction has been picked for the door to close
+		if dx != 0 || dy != 0 {
+			closing = false
+			pos, _ := player.Components["position"].(ecs.PositionComponent)
+			actionTaken = ecs.SystemCloseDoor(player, pos.X+dx, pos.Y+dy, entities, gameMap, &messageLog)
+		}
 	} else {
 		// Fire off the movement system
-		ecs.SystemMovement(entity, dx, dy, entities, gameMap, &messageLog)
+		ecs.SystemMovement(entity, dx, dy, entities, gameMap, gameCamera, &messageLog)
 	}
 
 	// Switch the game turn to the Mobs turn, if an action was taken. Some commands, like examine, or checking inventory
 	// do not cost an action
-	if actionTaken && !examining && !inMenu {
+	if actionTaken && !examining && !targeting && !inMenu && !closing {
 		gameTurn = MobTurn
 	}
 }
 
-func renderMap() {
-	// Render the game map. If a tile is blocked and blocks sight, draw a '#', if it is not blocked, and does not block
-	// sight, draw a '.'
+func handleHistoryInput(key int) {
+	// Scroll through, or search, the message history. While a search is being typed, keys go into the search text
+	// instead.
+	pageSize := WindowSizeY - 4
+
+	if historySearching {
+		switch key {
+		case blt.TK_RETURN, blt.TK_ENTER:
+			historySearching = false
+		case blt.TK_ESCAPE:
+			historySearching = false
+			historyFilter = ""
+		case blt.TK_BACKSPACE:
+			if len(historyFilter) > 0 {
+				historyFilter = historyFilter[:len(historyFilter)-1]
+			}
+		case blt.TK_SPACE:
+			historyFilter += " "
+		default:
+			if r := ui.MapBltKeyCodesToRunes(key); r != ' ' {
+				historyFilter += string(r)
+			}
+		}
+
+		historyScroll = 0
+		return
+	}
+
+	switch key {
+	case blt.TK_UP, blt.TK_K:
+		historyScroll++
+	case blt.TK_DOWN, blt.TK_J:
+		historyScroll--
+	case blt.TK_PAGEUP:
+		historyScroll += pageSize
+	case blt.TK_PAGEDOWN:
+		historyScroll -= pageSize
+	case blt.TK_HOME:
+		historyScroll = MessageHistoryLength
+	case blt.TK_END:
+		historyScroll = 0
+	case blt.TK_SLASH:
+		historySearching = true
+		historyFilter = ""
+	case blt.TK_1, blt.TK_2, blt.TK_3, blt.TK_4:
+		// Show or hide a whole category of messages, everywhere they are shown
+		category := key - blt.TK_1
+		messageLog.ShowCategory(category, !messageLog.CategoryShown(category))
+	case blt.TK_ESCAPE, blt.TK_P:
+		inMenu = false
+		history = false
+		historyFilter = ""
+		ui.ClearScreen(WindowSizeX, WindowSizeY)
+	}
+}
+
+func startTargeting(mode int) {
+	// Start picking a location on the map. Targeting uses the same cursor as the examine command, starting on the
+	// player. Enter (or f) confirms the location, and escape cancels.
+	targeting = true
+	targetingMode = mode
+
+	pos, _ := player.Components["position"].(ecs.PositionComponent)
+	examineCursor = &examinecursor.XCursor{X: pos.X, Y: pos.Y, Character: "X", Layer: ExamineLayer}
+}
+
+func panCamera(dx, dy int) {
+	// Move the camera around, independently of the player. The camera will only move somewhere that has at least some
+	// explored (or magically mapped) ground in view, so it cannot get lost in the unknown.
+	oldX, oldY := gameCamera.X, gameCamera.Y
+	gameCamera.Pan(dx, dy, MapWidth, MapHeight)
+
+	for x := gameCamera.X; x < gameCamera.X+gameCamera.Width; x++ {
+		for y := gameCamera.Y; y < gameCamera.Y+gameCamera.Height; y++ {
+			if gameMap.Tiles[x][y].Explored || gameMap.Tiles[x][y].Mapped {
+				return
+			}
+		}
+	}
+
+	gameCamera.X, gameCamera.Y = oldX, oldY
+}
+
+func renderOverview() {
+	// Draw the whole level at once, shrunk down to fit the screen if it has to be
+	ui.ClearScreen(WindowSizeX, WindowSizeY)
+
+	blockWidth := (MapWidth + WindowSizeX - 1) / WindowSizeX
+	blockHeight := (MapHeight + WindowSizeY - 2) / (WindowSizeY - 1)
+
+	blt.Layer(0)
+	blt.Color(blt.ColorFromName("white"))
+	blt.Print(0, 0, "Overview of depth "+strconv.Itoa(gameDungeon.CurrentLevel().Depth)+" - press any key to return")
+
+	creatures := knownCreatures()
+
+	for cellX := 0; cellX*blockWidth < MapWidth; cellX++ {
+		for cellY := 0; cellY*blockHeight < MapHeight; cellY++ {
+			character, color := summarizeBlock(cellX*blockWidth, cellY*blockHeight, blockWidth, blockHeight, creatures)
+
+			if character != "" {
+				blt.Color(blt.ColorFromName(color))
+				blt.Print(cellX, cellY+1, character)
+			}
+		}
+	}
+}
+
+func renderMinimap() {
+	// Draw a small map of the whole level in the bottom of the sidebar. The part of the level currently on screen is
+	// highlighted.
+	blockWidth := (MapWidth + MinimapWidth - 1) / MinimapWidth
+	blockHeight := (MapHeight + MinimapHeight - 1) / MinimapHeight
+
+	startX, startY := ViewAreaX+1, ViewAreaY-MinimapHeight-1
+
+	blt.Layer(0)
+
+	creatures := knownCreatures()
+
+	for cellX := 0; cellX < MinimapWidth; cellX++ {
+		for cellY := 0; cellY < MinimapHeight; cellY++ {
+			x, y := cellX*blockWidth, cellY*blockHeight
+
+			inView := x+blockWidth > gameCamera.X && x < gameCamera.X+gameCamera.Width && y+blockHeight > gameCamera.Y && y < gameCamera.Y+gameCamera.Height
+			if inView {
+				blt.BkColor(blt.ColorFromName(MinimapViewColor))
+			}
+
+			character, color := summarizeBlock(x, y, blockWidth, blockHeight, creatures)
+			if character == "" {
+				character, color = " ", "black"
+			}
+
+			blt.Color(blt.ColorFromName(color))
+			blt.Print(startX+cellX, startY+cellY, character)
+			blt.BkColor(blt.ColorFromName("black"))
+		}
+	}
+}
+
+func knownCreatures() map[*gamemap.Tile]ecs.AppearanceComponent {
+	// Return every creature the player knows the whereabouts of, either because they can see it, or sense it
+	creatures := map[*gamemap.Tile]ecs.AppearanceComponent{}
+	telepathic := player.HasComponent("telepathic")
+
+	for _, e := range entities {
+		if e == player || !ecs.IsCreature(e) {
+			continue
+		}
+
+		pos, _ := e.Components["position"].(ecs.PositionComponent)
+		app, _ := e.Components["appearance"].(ecs.AppearanceComponent)
+
+		if gameMap.IsVisibleToPlayer(pos.X, pos.Y) {
+			creatures[gameMap.Tiles[pos.X][pos.Y]] = app
+		} else if telepathic {
+			app.Color = ecs.TelepathyColor
+			creatures[gameMap.Tiles[pos.X][pos.Y]] = app
+		}
+	}
+
+	return creatures
+}
 
-	// First, set the every portion of the map seen by the camera to not visible. We'll decide what is visible based on
-	// the torch radius. In the process, clear every camera visible Tile on the map as well
-	for x := 0; x < MapWidth; x++ {
-		for y := 0; y < MapHeight; y++ {
-			gameMap.Tiles[x][y].Visible = false
+func summarizeBlock(startX, startY, width, height int, creatures map[*gamemap.Tile]ecs.AppearanceComponent) (string, string) {
+	// Pick how a block of tiles should be drawn on a shrunken down map. Whatever is most important in the block is
+	// shown: the player, then any creature the player knows about, then stairs, then anything remembered lying around,
+	// then open ground, then walls. Blocks the player knows nothing about come back empty.
+	bestRank, character, color := 0, "", ""
+
+	for x := startX; x < startX+width && x < MapWidth; x++ {
+		for y := startY; y < startY+height && y < MapHeight; y++ {
+			rank, tileCharacter, tileColor := summarizeTile(x, y, creatures)
+			if rank > bestRank {
+				bestRank, character, color = rank, tileCharacter, tileColor
+			}
 		}
 	}
 
+	return character, color
+}
+
+func summarizeTile(x, y int, creatures map[*gamemap.Tile]ecs.AppearanceComponent) (int, string, string) {
+	// Return how important a single tile is to show on a shrunken down map, along with how it should be drawn
+	tile := gameMap.Tiles[x][y]
+	tileType := tile.TileType()
+	pos, _ := player.Components["position"].(ecs.PositionComponent)
+
+	color := tileType.RememberedColor
+	if gameMap.IsVisibleToPlayer(x, y) {
+		color = tileType.LitColor
+	} else if !tile.Explored {
+		color = MappedColor
+	}
+
+	creature, known := creatures[tile]
+
+	switch {
+	case x == pos.X && y == pos.Y:
+		return 6, "@", "white"
+	case known:
+		return 5, creature.Character, creature.Color
+	case !tile.Explored && !tile.Mapped && tile.MemoryCharacter == "":
+		return 0, "", ""
+	case tile.Stairs == gamemap.StairsDown:
+		return 4, ">", color
+	case tile.Stairs == gamemap.StairsUp:
+		return 4, "<", color
+	case tile.MemoryDetected:
+		return 3, tile.MemoryCharacter, DetectedColor
+	case tile.MemoryCharacter != "":
+		return 3, tile.MemoryCharacter, tile.MemoryColor
+	case !tile.IsWall():
+		return 2, tileType.Character, color
+	}
+	return 1, tileType.Character, color
+}
+
+func renderLineOfFire(maxRange int) {
+	// Show the path a shot at the location under the targeting cursor would take. The path is drawn in red if it would
+	// hit something, and stops short of whatever it hits, so that stays in view.
+	path, hit := ecs.LineOfFire(player, examineCursor.X, examineCursor.Y, maxRange, entities, gameMap)
+
+	color := "light gray"
+	if hit {
+		color = "light red"
+		path = path[:len(path)-1]
+	}
+
+	blt.Layer(ExamineLayer)
+	blt.Color(blt.ColorFromName(color))
+
+	for _, point := range path {
+		cameraX, cameraY := gameCamera.ToCameraCoordinates(point.X, point.Y)
+		if cameraX >= 0 && cameraY >= 0 && gameMap.IsVisibleToPlayer(point.X, point.Y) {
+			blt.Print(cameraX, cameraY, "*")
+		}
+	}
+}
+
+func renderMap() {
+	// Render the game map. Each tile is drawn according to its type, brightly if it is in view, and dimmed if it has
+	// only been seen before
+
+	// First, clear every camera visible Tile on the map, and anything drawn over the top of it for the examine and
+	// targeting cursors
+	blt.Layer(ExamineLayer)
+	blt.ClearArea(0, 0, gameCamera.Width, gameCamera.Height)
+
 	for x := 0; x < gameCamera.Width; x++ {
 		for y := 0; y < gameCamera.Height; y++ {
 			// Clear both our primary layers, so we don't get any strange artifacts from one layer or the other getting
@@ -367,10 +940,15 @@ func renderMap() {
 	positionComponent, posOk := player.Components["position"].(ecs.PositionComponent)
 
 	if posOk {
-		gameCamera.MoveCamera(positionComponent.X, positionComponent.Y, MapWidth, MapHeight)
+		// The camera follows the player, unless they are looking around the map
+		if !freeLook {
+			gameCamera.MoveCamera(positionComponent.X, positionComponent.Y, MapWidth, MapHeight)
+		}
 
-		// Next figure out what is visible to the player, and what is not.
-		fieldOfView.RayCast(positionComponent.X, positionComponent.Y, gameMap)
+		// Next figure out what is lit, and from that, what is visible to the player, and what is not.
+		ecs.SystemLighting(entities, gameMap, fieldOfView.Algorithm)
+		fieldOfView.Compute(positionComponent.X, positionComponent.Y, gameMap)
+		ecs.SystemRemember(entities, gameMap)
 	}
 
 	// Now draw each tile that should appear on the screen, if its visible, or explored
@@ -379,27 +957,66 @@ func renderMap() {
 		for y := 0; y < gameCamera.Height; y++ {
 			mapX, mapY := gameCamera.X+x, gameCamera.Y+y
 
-			if gameMap.Tiles[mapX][mapY].Visible {
-				if gameMap.Tiles[mapX][mapY].IsWall() {
-					blt.Color(blt.ColorFromName("white"))
-					blt.Print(x, y, "#")
-				} else {
-					blt.Color(blt.ColorFromName("white"))
-					blt.Print(x, y, ".")
+			tile := gameMap.Tiles[mapX][mapY]
+			tileType := tile.TileType()
+
+			character := tileType.Character
+			if tile.Stairs == gamemap.StairsDown {
+				character = ">"
+			} else if tile.Stairs == gamemap.StairsUp {
+				character = "<"
+			}
+
+			if gameMap.IsVisibleToPlayer(mapX, mapY) {
+				// Tiles lit by something other than the players torch are tinted by the color of the light
+				if tile.LightColor != "" {
+					blt.BkColor(blt.ColorFromName(lightTint(tile)))
 				}
-			} else if gameMap.Tiles[mapX][mapY].Explored {
-				if gameMap.Tiles[mapX][mapY].IsWall() {
-					blt.Color(blt.ColorFromName("gray"))
-					blt.Print(x, y, "#")
-				} else {
-					blt.Color(blt.ColorFromName("gray"))
-					blt.Print(x, y, ".")
+
+				blt.Color(blt.ColorFromName(tileType.LitColor))
+				blt.Print(x, y, character)
+				blt.BkColor(blt.ColorFromName("black"))
+			} else {
+				if tile.Explored {
+					blt.Color(blt.ColorFromName(tileType.RememberedColor))
+					blt.Print(x, y, character)
+				} else if tile.Mapped {
+					// Tiles revealed by magic have never actually been seen, and are drawn in a color of their own
+					blt.Color(blt.ColorFromName(MappedColor))
+					blt.Print(x, y, character)
+				}
+
+				// Anything remembered (or sensed) lying on the tile is drawn dimmed, on the layer that is cleared every
+				// frame, so it goes away as soon as the tile comes back into view
+				if tile.MemoryCharacter != "" {
+					color := dimmed(tile.MemoryColor)
+					if tile.MemoryDetected {
+						color = blt.ColorFromName(DetectedColor)
+					}
+
+					blt.Layer(ecs.ActorLayer)
+					blt.Color(color)
+					blt.Print(x, y, tile.MemoryCharacter)
+					blt.Layer(MapLayer)
 				}
 			}
 		}
 	}
 }
 
+func dimmed(color string) uint32 {
+	// Return a half transparent version of the named color, which shows up darker against the black background
+	return blt.ColorFromName(color)&0x00FFFFFF | 0x80000000
+}
+
+func lightTint(tile *gamemap.Tile) string {
+	// Brightly lit tiles get a stronger tint than dimly lit ones
+	if tile.Light > 2 {
+		return "darker " + tile.LightColor
+	}
+	return "darkest " + tile.LightColor
+}
+
 func renderSideBar() {
 	blt.Layer(0)
 	blt.ClearArea(ViewAreaX, 0, WindowSizeX, WindowSizeY)
@@ -410,6 +1027,30 @@ func renderSideBar() {
 		ui.PrintBasicCharacterInfo(playerAppearance.Name, ViewAreaX)
 		ui.PrintStats(playerHp.Hp, playerHp.MaxHP, ViewAreaX)
 	}
+
+	ui.PrintDepth(gameDungeon.CurrentLevel().Depth, ViewAreaX)
+
+	// List out any allies the player has, along with how healthy they are
+	allies := ecs.GetFollowers(player, entities)
+	if len(allies) > 0 {
+		y := 8
+		ui.PrintAlliesHeader(ViewAreaX, y)
+
+		for _, ally := range allies {
+			if y+2 >= ViewAreaY-MinimapHeight-1 {
+				break
+			}
+
+			if ally.HasComponents([]string{"appearance", "hitpoints"}) {
+				app, _ := ally.Components["appearance"].(ecs.AppearanceComponent)
+				hp, _ := ally.Components["hitpoints"].(ecs.HitPointComponent)
+				ui.PrintAlly(app.Name, app.Color, hp.Hp, hp.MaxHP, ViewAreaX, y+1)
+				y += 2
+			}
+		}
+	}
+
+	renderMinimap()
 }
 
 func renderInventory(title string) {
@@ -429,7 +1070,7 @@ func renderInventory(title string) {
 				lootable, _ := inv.Items[i].Components["lootable"].(ecs.LootableComponent)
 
 				key := string(ui.MapBltKeyCodesToRunes(lootable.Key))
-				name := key + " - " + "[color=" + app.Color + "]" + app.Name + "[/color]"
+				name := key + " - " + app.ColoredName()
 
 				items[name]++
 			}
@@ -447,7 +1088,7 @@ func renderInformationScreen(item *ecs.GameEntity) {
 		desc, _ := item.Components["description"].(ecs.DescriptionComponent)
 
 		key := string(ui.MapBltKeyCodesToRunes(lootable.Key))
-		title := key + " - [color=" + app.Color + "]" + app.Name + "[/color]"
+		title := key + " - " + app.ColoredName()
 
 		occurences := ecs.CountItemInstances(player, item)
 
@@ -470,14 +1111,23 @@ func examine(dx, dy int) {
 
 	if gameMap.IsVisibleAndExplored(examineCursor.X, examineCursor.Y) {
 		presentEntities := ecs.GetEntityNamesPresentAtLocation(entities, examineCursor.X, examineCursor.Y)
+
+		// Traps that have been found are described, so the player knows what they are in for
+		if trap := ecs.GetRevealedTrapAtLocation(entities, examineCursor.X, examineCursor.Y); trap != nil && trap.HasComponent("description") {
+			description, _ := trap.Components["description"].(ecs.DescriptionComponent)
+			presentEntities += " - " + description.ShortDesc
+		}
+
 		if presentEntities != "" {
 			ui.PrintToMessageArea(presentEntities, ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
 		} else {
 			tile := gameMap.Tiles[examineCursor.X][examineCursor.Y]
-			if tile.IsWall() {
-				ui.PrintToMessageArea("A cavern wall, made of some kind of rock", ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
+			if tile.Stairs == gamemap.StairsDown {
+				ui.PrintToMessageArea("A rough staircase, leading further down into the dark", ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
+			} else if tile.Stairs == gamemap.StairsUp {
+				ui.PrintToMessageArea("A rough staircase, leading back up", ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
 			} else {
-				ui.PrintToMessageArea("A cavern floor, covered in dirt and stones", ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
+				ui.PrintToMessageArea(tile.TileType().Description, ViewAreaY, WindowSizeX, WindowSizeY, examineCursor.Layer)
 			}
 		}
 	} else {
@@ -486,23 +1136,409 @@ func examine(dx, dy int) {
 }
 
 /* Generator functions */
-func GenerateAndPopulateCavern() (int, int, []*ecs.GameEntity) {
-	gameMap := gameMap.GenerateCavern()
+func generateLevel(depth int) *dungeon.Level {
+	// Create a new level of the dungeon at the given depth, complete with stairs leading up and down, and populate it.
+	// Levels alternate between natural caverns, and carved out rooms (laid out one of a few different ways). Deeper
+	// levels are more dangerous.
+	levelMap := &gamemap.Map{Width: MapWidth, Height: MapHeight}
+	levelMap.InitializeMap()
+
+	generator, ok := gamemap.GeneratorByName(levelGenerator(depth))
+	if !ok {
+		generator, _ = gamemap.GeneratorByName("cavern")
+	}
+	floor := generator.Generate(levelMap)
+
+	if len(floor) < 2 {
+		// There has to be room for both staircases. No generator should leave less than that, but if one ever does, an
+		// open arena is used in its place, rather than leaving the level with nowhere to stand.
+		generator = &gamemap.ArenaGenerator{}
+		floor = generator.Generate(levelMap)
+	}
+
+	// A generator that builds rooms, but did not manage to fit any in, has its level treated like a cavern
+	var rooms []gamemap.Rect
+	var graph [][]int
+
+	if roomGenerator, ok := generator.(gamemap.RoomGenerator); ok {
+		rooms, graph = roomGenerator.Rooms()
+	}
+
+	if len(rooms) > 0 {
+		// The stairs up go in the first room, and the stairs down in whichever room is furthest from it
+		upX, upY := rooms[0].Center()
+		downX, downY := rooms[len(rooms)-1].Center()
+
+		for _, room := range rooms {
+			x, y := room.Center()
+			if (x-upX)*(x-upX)+(y-upY)*(y-upY) > (downX-upX)*(downX-upX)+(downY-upY)*(downY-upY) {
+				downX, downY = x, y
+			}
+		}
+
+		if downX == upX && downY == upY {
+			// There is only the one room, so the stairs down go in whichever corner of it is furthest from the stairs up
+			downX, downY = rooms[0].X1, rooms[0].Y1
+			for _, corner := range [][2]int{{rooms[0].X2, rooms[0].Y1}, {rooms[0].X1, rooms[0].Y2}, {rooms[0].X2, rooms[0].Y2}} {
+				if (corner[0]-upX)*(corner[0]-upX)+(corner[1]-upY)*(corner[1]-upY) > (downX-upX)*(downX-upX)+(downY-upY)*(downY-upY) {
+					downX, downY = corner[0], corner[1]
+				}
+			}
+		}
+
+		levelMap.PlaceStairs(upX, upY, gamemap.StairsUp)
+		levelMap.PlaceStairs(downX, downY, gamemap.StairsDown)
+
+		// Rooms have doors, and now and then, an out of the way room is locked up. The key will be somewhere else on
+		// the level.
+		levelMap.PlaceDoors(rooms, DoorChance)
+		for _, i := range gamemap.RoomDeadEnds(graph) {
+			if i != 0 && !rooms[i].Contains(downX, downY) && rand.Intn(100) < LockedRoomChance {
+				levelMap.LockRoom(rooms[i])
+			}
+		}
+
+		levelMap.AddTerrainFeatures(depth)
+		spawns := placeVaults(levelMap, depth)
+		levelEntities := populateRooms(levelMap, rooms, graph, depth)
+		levelEntities = placeBraziers(levelMap, rooms, levelEntities)
+		levelEntities = spawnVaultContents(spawns, levelEntities, depth)
+		levelEntities = placeTraps(levelMap, levelEntities, depth)
+
+		return &dungeon.Level{Depth: depth, Map: levelMap, Entities: placeKeys(levelMap, levelEntities)}
+	}
+
+	// The stairs up go anywhere, and the stairs down go as far away from them as a handful of attempts can find, so
+	// the player has to cross the level
+	up := floor[rand.Int()%len(floor)]
+	down := up
+
+	for down == up {
+		down = floor[rand.Int()%len(floor)]
+	}
+
+	for i := 0; i < 20; i++ {
+		candidate := floor[rand.Int()%len(floor)]
+		if tileDistance(up, candidate) > tileDistance(up, down) {
+			down = candidate
+		}
+	}
+
+	levelMap.PlaceStairs(up.X, up.Y, gamemap.StairsUp)
+	levelMap.PlaceStairs(down.X, down.Y, gamemap.StairsDown)
+
+	levelMap.AddTerrainFeatures(depth)
+
+	// Vaults put walls down on what was open floor, so the floor has to be found again afterwards
+	spawns := placeVaults(levelMap, depth)
+	levelEntities := populateCavern(levelMap.FloorTiles(), depth)
+	levelEntities = spawnVaultContents(spawns, levelEntities, depth)
+	levelEntities = placeTraps(levelMap, levelEntities, depth)
+
+	return &dungeon.Level{Depth: depth, Map: levelMap, Entities: placeKeys(levelMap, levelEntities)}
+}
+
+func placeTraps(levelMap *gamemap.Map, levelEntities []*ecs.GameEntity, depth int) []*ecs.GameEntity {
+	// Hide some traps around the level. Deeper levels have more of them.
+	used := map[*gamemap.Tile]bool{}
+
+	for placed, attempts := 0, 0; placed < 4+depth && attempts < 200; attempts++ {
+		tile := levelMap.Tiles[rand.Intn(levelMap.Width)][rand.Intn(levelMap.Height)]
+
+		if used[tile] || tile.Blocked || tile.IsHazardous() || tile.IsDoor() || tile.Stairs != gamemap.NoStairs || len(ecs.GetEntitiesPresentAtLocation(levelEntities, tile.X, tile.Y)) > 0 {
+			continue
+		}
+
+		used[tile] = true
+		levelEntities = append(levelEntities, createTrap(tile.X, tile.Y, depth))
+		placed++
+	}
+
+	return levelEntities
+}
+
+func placeBraziers(levelMap *gamemap.Map, rooms []gamemap.Rect, levelEntities []*ecs.GameEntity) []*ecs.GameEntity {
+	// Light a brazier in some of the rooms. Braziers stand in a corner, so they are never in the way, unless a corridor
+	// happens to run through that corner, in which case the room goes without.
+	for _, room := range rooms {
+		if rand.Intn(100) >= BrazierChance {
+			continue
+		}
+
+		corners := [][2]int{{room.X1, room.Y1}, {room.X2, room.Y1}, {room.X1, room.Y2}, {room.X2, room.Y2}}
+		corner := corners[rand.Intn(len(corners))]
+		tile := levelMap.Tiles[corner[0]][corner[1]]
+
+		if tile.Type != gamemap.TileFloor || tile.Stairs != gamemap.NoStairs || len(ecs.GetEntitiesPresentAtLocation(levelEntities, tile.X, tile.Y)) > 0 {
+			continue
+		}
+
+		inCorridor := false
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				if !room.Contains(tile.X+dx, tile.Y+dy) && !levelMap.Tiles[tile.X+dx][tile.Y+dy].IsWall() {
+					inCorridor = true
+				}
+			}
+		}
 
-	pos := rand.Int() % len(gameMap)
-	playerX, playerY := gameMap[pos].X, gameMap[pos].Y
+		if !inCorridor {
+			levelEntities = append(levelEntities, createBrazier(tile.X, tile.Y))
+		}
+	}
+
+	return levelEntities
+}
+
+func placeKeys(levelMap *gamemap.Map, levelEntities []*ecs.GameEntity) []*ecs.GameEntity {
+	// Leave a key somewhere on the level for every locked door, so nothing is locked away for good. Keys are only put
+	// where the player can walk to from the stairs they arrive on, without needing a key to get there. If there are not
+	// enough places to put them, or the rest of the level has somehow been cut off from the stairs by a locked door,
+	// doors are unlocked instead.
+	locked := levelMap.LockedDoors()
+
+	upX, upY, ok := levelMap.FindStairs(gamemap.StairsUp)
+	if len(locked) == 0 || !ok {
+		return levelEntities
+	}
+
+	if !levelMap.StairsConnected() {
+		for _, door := range locked {
+			door.SetType(gamemap.TileDoorClosed)
+		}
+		return levelEntities
+	}
 
-	entities := populateCavern(gameMap)
+	reachable := levelMap.ReachableTiles(upX, upY, func(tile *gamemap.Tile) bool {
+		return !tile.IsWall() && tile.Type != gamemap.TileDoorLocked
+	})
 
-	return playerX, playerY, entities
+	placed := 0
+	for _, i := range rand.Perm(len(reachable)) {
+		if placed == len(locked) {
+			break
+		}
+
+		tile := reachable[i]
+		if tile.Blocked || tile.IsHazardous() || tile.Stairs != gamemap.NoStairs || len(ecs.GetEntitiesPresentAtLocation(levelEntities, tile.X, tile.Y)) > 0 {
+			continue
+		}
+
+		levelEntities = append(levelEntities, createKey(tile.X, tile.Y))
+		placed++
+	}
+
+	for _, door := range locked[placed:] {
+		door.SetType(gamemap.TileDoorClosed)
+	}
+
+	return levelEntities
 }
 
-func populateCavern(mainCave []*gamemap.Tile) []*ecs.GameEntity {
-	// Randomly sprinkle some Orcs, Trolls, and Goblins around the newly created cavern
+func placeVaults(levelMap *gamemap.Map, depth int) []gamemap.VaultSpawn {
+	// Stamp a few of the hand designed vaults into the level, wherever they will fit. Returns everything the vaults
+	// want spawned inside them.
+	var spawns []gamemap.VaultSpawn
+
+	for i := 0; i < MaxVaultsPerLevel; i++ {
+		vault := gamemap.ChooseVault(vaults, depth)
+		if vault == nil {
+			break
+		}
+
+		if vaultSpawns, ok := levelMap.PlaceVault(vault, 100); ok {
+			spawns = append(spawns, vaultSpawns...)
+		}
+	}
+
+	return spawns
+}
+
+func spawnVaultContents(spawns []gamemap.VaultSpawn, levelEntities []*ecs.GameEntity, depth int) []*ecs.GameEntity {
+	// Create whatever the vaults asked for. Anything already standing in the spot is cleared out of the way, as the
+	// vault's own contents come first, except for keys and traps, which are left where they are for the vault's
+	// contents to share the spot with.
+	for _, spawn := range spawns {
+		for _, e := range ecs.GetEntitiesPresentAtLocation(levelEntities, spawn.X, spawn.Y) {
+			if e.HasComponent("key") || e.HasComponent("trap") {
+				continue
+			}
+
+			for i := range levelEntities {
+				if levelEntities[i] == e {
+					levelEntities = append(levelEntities[:i], levelEntities[i+1:]...)
+					break
+				}
+			}
+		}
+
+		switch spawn.Name {
+		case "monster":
+			levelEntities = append(levelEntities, createMonster(spawn.X, spawn.Y, depth))
+		case "guardian":
+			// Guardians are picked as though they were a few levels further down, so they are tougher than usual
+			levelEntities = append(levelEntities, createMonster(spawn.X, spawn.Y, depth+3))
+		case "item":
+			levelEntities = append(levelEntities, createItem(spawn.X, spawn.Y))
+		case "brazier":
+			levelEntities = append(levelEntities, createBrazier(spawn.X, spawn.Y))
+		}
+	}
+
+	return levelEntities
+}
+
+func levelGenerator(depth int) string {
+	// Odd levels are caverns, every other one of which has its smaller caves tunnelled together. Past the first few
+	// levels, the rest of the odd levels are dug out by drunkards, or are half cavern and half rooms. Even levels are
+	// built from rooms, alternating between scattered rooms joined by corridors, and tightly packed rooms from
+	// splitting up the map.
+	switch {
+	case depth%4 == 3:
+		return "connected_cavern"
+	case depth%2 == 1 && depth < 5:
+		return "cavern"
+	case depth%8 == 5:
+		return "drunkard"
+	case depth%8 == 1:
+		return "mixed"
+	case depth%4 == 2:
+		return "rooms"
+	case depth%8 == 0:
+		return "bsp_winding"
+	default:
+		return "bsp"
+	}
+}
+
+func tileDistance(a, b *gamemap.Tile) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+func useStairs(direction int) bool {
+	// Take the stairs the player is standing on, up or down to the next level. The player brings along anything they
+	// are carrying, and any allies close enough to follow them (that have not been told to stay put). Returns true if
+	// the player actually went anywhere.
+	pos, _ := player.Components["position"].(ecs.PositionComponent)
+
+	if gameMap.Tiles[pos.X][pos.Y].Stairs != direction {
+		if direction == gamemap.StairsDown {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "There are no stairs leading down here.")
+		} else {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "There are no stairs leading up here.")
+		}
+		return false
+	}
+
+	index := gameDungeon.Current + 1
+	arrival := gamemap.StairsUp
+	if direction == gamemap.StairsUp {
+		index = gameDungeon.Current - 1
+		arrival = gamemap.StairsDown
+	}
+
+	if index < 0 {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityWarning, "The way back to the surface has caved in. There is no going back now.")
+		return false
+	}
+
+	if !gameDungeon.HasLevel(index) {
+		gameDungeon.AddLevel(generateLevel(index + 1))
+	}
+
+	// Arrive on the matching staircase, with any allies gathered around. An ally close enough to follow, but with
+	// nowhere to stand at the other end, stays behind on this level instead.
+	from := pos
+	next := gameDungeon.Levels[index]
+	x, y := arrivalPoint(next.Map, arrival)
+	pos.X, pos.Y = x, y
+	player.RemoveComponent("position")
+	player.AddComponent("position", pos)
+
+	travellers := []*ecs.GameEntity{player}
+	occupants := append([]*ecs.GameEntity{player}, next.Entities...)
+	for _, ally := range ecs.GetFollowers(player, entities) {
+		allyPos, _ := ally.Components["position"].(ecs.PositionComponent)
+		follower, _ := ally.Components["follower"].(ecs.FollowerComponent)
+
+		dx, dy := allyPos.X-from.X, allyPos.Y-from.Y
+		if follower.Order != ecs.OrderStay && dx*dx+dy*dy <= (ecs.FollowDistance+1)*(ecs.FollowDistance+1) {
+			if ecs.PlaceNear(ally, x, y, occupants, next.Map) {
+				travellers = append(travellers, ally)
+				occupants = append(occupants, ally)
+			} else {
+				app, _ := ally.Components["appearance"].(ecs.AppearanceComponent)
+				messageLog.Send(ui.CategorySystem, ui.SeverityWarning, app.ColoredName()+" has no room to follow you, and stays behind.")
+			}
+		}
+	}
+
+	var remaining []*ecs.GameEntity
+	for _, e := range entities {
+		if e == nil || isTraveller(e, travellers) {
+			continue
+		}
+
+		// Anything being carried by one of the travellers comes along with them
+		if e.HasComponent("lootable") {
+			lootable, _ := e.Components["lootable"].(ecs.LootableComponent)
+			if lootable.InInventory && isTraveller(lootable.Owner, travellers) {
+				travellers = append(travellers, e)
+				continue
+			}
+		}
+
+		remaining = append(remaining, e)
+	}
+
+	level := gameDungeon.ChangeLevel(index, travellers, remaining)
+	gameMap = level.Map
+	entities = level.Entities
+
+	ui.ClearScreen(WindowSizeX, WindowSizeY)
+
+	if direction == gamemap.StairsDown {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You descend deeper into the caverns...")
+	} else {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You climb back up the stairs...")
+	}
+
+	return true
+}
+
+func arrivalPoint(levelMap *gamemap.Map, direction int) (int, int) {
+	// Return where to put the player when they arrive on a level: on the staircase going in the given direction. If
+	// there somehow is no such staircase, they are put on the first open tile that is safe to stand on instead, rather
+	// than inside the rock.
+	if x, y, ok := levelMap.FindStairs(direction); ok {
+		return x, y
+	}
+
+	for _, tile := range levelMap.FloorTiles() {
+		if !tile.Blocked && !tile.IsHazardous() {
+			return tile.X, tile.Y
+		}
+	}
+
+	return levelMap.Width / 2, levelMap.Height / 2
+}
+
+func isTraveller(entity *ecs.GameEntity, travellers []*ecs.GameEntity) bool {
+	for _, t := range travellers {
+		if t == entity {
+			return true
+		}
+	}
+	return false
+}
+
+func populateCavern(mainCave []*gamemap.Tile, depth int) []*ecs.GameEntity {
+	// Randomly sprinkle some Orcs, Trolls, and Goblins around the newly created cavern. The deeper the level, the more
+	// monsters there are, and the more likely they are to be the nastier kinds.
 	var entities []*ecs.GameEntity
-	var createdEntity *ecs.GameEntity
 
-	for i := 0; i < 10; i++ {
+	for i := 0; i < 8+depth*2; i++ {
 		x := 0
 		y := 0
 		locationFound := false
@@ -511,63 +1547,14 @@ func populateCavern(mainCave []*gamemap.Tile) []*ecs.GameEntity {
 			pos := rand.Int() % len(mainCave)
 			x = mainCave[pos].X
 			y = mainCave[pos].Y
-			if ecs.GetBlockingEntitiesAtLocation(entities, x, y) == nil {
+			if ecs.GetBlockingEntitiesAtLocation(entities, x, y) == nil && mainCave[pos].Stairs == gamemap.NoStairs && !mainCave[pos].Blocked && !mainCave[pos].IsHazardous() {
 				locationFound = true
 				break
 			}
 		}
 
 		if locationFound {
-			chance := rand.Intn(100)
-			if chance <= 5 {
-				// Create a Troll
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance":     ecs.AppearanceComponent{Layer: ActorLayer, Character: "T", Color: "dark green", Name: "Troll"},
-					"hitpoints":      ecs.HitPointComponent{Hp: 20, MaxHP: 20},
-					"block":          ecs.BlockingComponent{},
-					"movement":       ecs.MovementComponent{},
-					"basic_melee_ai": ecs.BasicMeleeAIComponent{},
-					"attacker":       ecs.AttackerComponent{Attack: 10, Defense: 7},
-					"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
-			} else if chance > 5 && chance <= 20 {
-				// Create an Orc
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance":     ecs.AppearanceComponent{Layer: ActorLayer, Character: "o", Color: "darker green", Name: "Orc"},
-					"hitpoints":      ecs.HitPointComponent{Hp: 15, MaxHP: 15},
-					"block":          ecs.BlockingComponent{},
-					"movement":       ecs.MovementComponent{},
-					"basic_melee_ai": ecs.BasicMeleeAIComponent{},
-					"attacker":       ecs.AttackerComponent{Attack: 7, Defense: 5},
-					"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
-			} else if chance > 20 && chance <= 70 {
-				// Create a Goblin
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance":     ecs.AppearanceComponent{Layer: ActorLayer, Character: "g", Color: "green", Name: "Goblin"},
-					"hitpoints":      ecs.HitPointComponent{Hp: 5, MaxHP: 5},
-					"block":          ecs.BlockingComponent{},
-					"movement":       ecs.MovementComponent{},
-					"basic_melee_ai": ecs.BasicMeleeAIComponent{},
-					"attacker":       ecs.AttackerComponent{Attack: 2, Defense: 2},
-					"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
-			} else if chance > 70 {
-				// Create a reproducing Fungus
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance": ecs.AppearanceComponent{Layer: ActorLayer, Character: "f", Color: "yellow", Name: "Fungus"},
-					"hitpoints":  ecs.HitPointComponent{Hp: 5, MaxHP: 5},
-					"block":      ecs.BlockingComponent{},
-					"reproducer": ecs.ReproducesComponent{MaxTimes: 8, TimesRemaining: 8, PercentChance: 25},
-					"killable":   ecs.KillableComponent{Name: "Remains of", Color: "yellow", Character: "."}})
-			}
-
-			entities = append(entities, createdEntity)
+			entities = append(entities, createMonster(x, y, depth))
 		} else {
 			// No location was found after 50 tries, which means the map is quite full. Stop here and return.
 			break
@@ -585,39 +1572,14 @@ func populateCavern(mainCave []*gamemap.Tile) []*ecs.GameEntity {
 			pos := rand.Int() % len(mainCave)
 			x = mainCave[pos].X
 			y = mainCave[pos].Y
-			if ecs.GetBlockingEntitiesAtLocation(entities, x, y) == nil {
+			if ecs.GetBlockingEntitiesAtLocation(entities, x, y) == nil && mainCave[pos].Stairs == gamemap.NoStairs && !mainCave[pos].Blocked && !mainCave[pos].IsHazardous() {
 				locationFound = true
 				break
 			}
 		}
 
 		if locationFound {
-			chance := rand.Intn(100)
-
-			if chance >= 49 {
-				// Create a healing potion
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "!", Color: "dark red", Name: "Dark Red Potion"},
-					"lootable":    ecs.LootableComponent{InInventory: false, ID: 1},
-					"stackable":   ecs.StackableComponent{},
-					"description": ecs.DescriptionComponent{ShortDesc: "An unmarked, single dose, vial of a dark red liquid."}})
-
-				entities = append(entities, createdEntity)
-			} else {
-				// Create a healing potion
-				createdEntity = &ecs.GameEntity{}
-				createdEntity.SetupGameEntity()
-				createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
-					"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "!", Color: "light green", Name: "Bright Green Potion"},
-					"lootable":    ecs.LootableComponent{InInventory: false, ID: 2},
-					"stackable":   ecs.StackableComponent{},
-					"description": ecs.DescriptionComponent{ShortDesc: "An unmarked, single dose, vial of a bright green liquid."}})
-
-				entities = append(entities, createdEntity)
-			}
-
+			entities = append(entities, createItem(x, y))
 		} else {
 			// No location was found after 50 tries, which means the map is quite full. Stop here and return.
 			break
@@ -626,3 +1588,341 @@ func populateCavern(mainCave []*gamemap.Tile) []*ecs.GameEntity {
 
 	return entities
 }
+
+func populateRooms(levelMap *gamemap.Map, rooms []gamemap.Rect, graph [][]int, depth int) []*ecs.GameEntity {
+	// Populate a rooms and corridors level. Each room (other than the one the player arrives in) gets a few monsters,
+	// and the dead ends, which are out of the way, are where items tend to collect.
+	var entities []*ecs.GameEntity
+
+	for i := 1; i < len(rooms); i++ {
+		count := rand.Intn(2 + depth/2)
+
+		for j := 0; j < count; j++ {
+			if x, y, ok := findOpenTileInRoom(levelMap, rooms[i], entities); ok {
+				entities = append(entities, createMonster(x, y, depth))
+			}
+		}
+	}
+
+	deadEnds := gamemap.RoomDeadEnds(graph)
+
+	for i := range rooms {
+		count := 0
+		if containsRoom(deadEnds, i) {
+			count = 1 + rand.Intn(2)
+		} else if rand.Intn(100) < 30 {
+			count = 1
+		}
+
+		for j := 0; j < count; j++ {
+			if x, y, ok := findOpenTileInRoom(levelMap, rooms[i], entities); ok {
+				entities = append(entities, createItem(x, y))
+			}
+		}
+	}
+
+	return entities
+}
+
+func findOpenTileInRoom(levelMap *gamemap.Map, room gamemap.Rect, entities []*ecs.GameEntity) (int, int, bool) {
+	// Attempt to find a clear location inside the room, that is not a staircase, or blocked, or dangerous to stand on
+	for i := 0; i < 20; i++ {
+		x := room.X1 + rand.Intn(room.X2-room.X1+1)
+		y := room.Y1 + rand.Intn(room.Y2-room.Y1+1)
+
+		if levelMap.Tiles[x][y].Stairs == gamemap.NoStairs && !levelMap.Tiles[x][y].Blocked && !levelMap.Tiles[x][y].IsHazardous() && len(ecs.GetEntitiesPresentAtLocation(entities, x, y)) == 0 {
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
+func containsRoom(rooms []int, room int) bool {
+	for _, r := range rooms {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+func createMonster(x, y, depth int) *ecs.GameEntity {
+	// Create a random monster at the given location. Deeper levels are more likely to produce the nastier kinds.
+	var createdEntity *ecs.GameEntity
+
+	// Some monsters start off asleep, and can be snuck past (or up on)
+	state := ecs.Unaware
+	if rand.Intn(100) < 30 {
+		state = ecs.Asleep
+	}
+
+	chance := rand.Intn(100)
+	if rand.Intn(100) < (depth-1)*8 {
+		// Deeper down, roll again for only Trolls and Orcs
+		chance = rand.Intn(21)
+	}
+
+	if chance <= 5 {
+		// Create a Troll
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":     ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "T", Color: "dark green", Name: "Troll"},
+			"hitpoints":      ecs.HitPointComponent{Hp: 20, MaxHP: 20},
+			"block":          ecs.BlockingComponent{},
+			"movement":       ecs.MovementComponent{},
+			"basic_melee_ai": ecs.BasicMeleeAIComponent{},
+			"attacker":       ecs.AttackerComponent{Attack: 10, Defense: 7},
+			"perception":     ecs.PerceptionComponent{SightRadius: 6, Hearing: 1, State: state},
+			"faction":        ecs.FactionComponent{Name: "trolls"},
+			"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
+	} else if chance > 5 && chance <= 20 {
+		// Create an Orc
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":     ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "o", Color: "darker green", Name: "Orc"},
+			"hitpoints":      ecs.HitPointComponent{Hp: 15, MaxHP: 15},
+			"block":          ecs.BlockingComponent{},
+			"movement":       ecs.MovementComponent{CanOpenDoors: true},
+			"basic_melee_ai": ecs.BasicMeleeAIComponent{},
+			"attacker":       ecs.AttackerComponent{Attack: 7, Defense: 5},
+			"perception":     ecs.PerceptionComponent{SightRadius: 8, Hearing: 2, State: state},
+			"faction":        ecs.FactionComponent{Name: "orcs"},
+			"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
+	} else if chance > 20 && chance <= 60 {
+		// Create a Goblin
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":     ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "g", Color: "green", Name: "Goblin"},
+			"hitpoints":      ecs.HitPointComponent{Hp: 5, MaxHP: 5},
+			"block":          ecs.BlockingComponent{},
+			"movement":       ecs.MovementComponent{CanOpenDoors: true},
+			"basic_melee_ai": ecs.BasicMeleeAIComponent{},
+			"attacker":       ecs.AttackerComponent{Attack: 2, Defense: 2},
+			"perception":     ecs.PerceptionComponent{SightRadius: 7, Hearing: 3, State: state},
+			"faction":        ecs.FactionComponent{Name: "goblins"},
+			"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
+	} else if chance > 60 && chance <= 70 {
+		// Create a Kobold Archer, who would much rather shoot you from a distance
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance": ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "k", Color: "light orange", Name: "Kobold Archer"},
+			"hitpoints":  ecs.HitPointComponent{Hp: 6, MaxHP: 6},
+			"block":      ecs.BlockingComponent{},
+			"movement":   ecs.MovementComponent{CanOpenDoors: true},
+			"ranged_ai":  ecs.RangedAIComponent{Range: 7, PreferredDistance: 4, Projectile: "-", ProjectileColor: "light orange", ProjectileName: "an arrow"},
+			"attacker":   ecs.AttackerComponent{Attack: 4, Defense: 3},
+			"perception": ecs.PerceptionComponent{SightRadius: 9, Hearing: 2, State: state},
+			"faction":    ecs.FactionComponent{Name: "kobolds"},
+			"killable":   ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
+	} else if chance > 90 {
+		// Create a Spitting Fungus. It cannot move, but will spit at anything that comes close enough
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance": ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "f", Color: "light purple", Name: "Spitting Fungus"},
+			"hitpoints":  ecs.HitPointComponent{Hp: 4, MaxHP: 4},
+			"block":      ecs.BlockingComponent{},
+			"ranged_ai":  ecs.RangedAIComponent{Range: 4, PreferredDistance: 0, Projectile: "*", ProjectileColor: "light purple", ProjectileName: "a glob of spores"},
+			"attacker":   ecs.AttackerComponent{Attack: 3, Defense: 1},
+			"perception": ecs.PerceptionComponent{SightRadius: 4, Hearing: 0, State: ecs.Unaware},
+			"faction":    ecs.FactionComponent{Name: "fungi"},
+			"killable":   ecs.KillableComponent{Name: "Remains of", Color: "light purple", Character: "."}})
+	} else if chance > 70 && chance <= 74 {
+		// Create a stray Cave Dog. It wanders about on its own, but will join the player if they say hello
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":     ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "d", Color: "light amber", Name: "Cave Dog"},
+			"hitpoints":      ecs.HitPointComponent{Hp: 10, MaxHP: 10},
+			"block":          ecs.BlockingComponent{},
+			"movement":       ecs.MovementComponent{},
+			"basic_melee_ai": ecs.BasicMeleeAIComponent{},
+			"attacker":       ecs.AttackerComponent{Attack: 4, Defense: 3},
+			"perception":     ecs.PerceptionComponent{SightRadius: 8, Hearing: 4, State: ecs.Unaware},
+			"faction":        ecs.FactionComponent{Name: "strays"},
+			"recruitable":    ecs.RecruitableComponent{Faction: "player"},
+			"killable":       ecs.KillableComponent{Name: "Remains of", Color: "dark red", Character: "%"}})
+	} else if chance > 70 {
+		// Create a reproducing Fungus
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance": ecs.AppearanceComponent{Layer: ecs.ActorLayer, Character: "f", Color: "yellow", Name: "Fungus"},
+			"hitpoints":  ecs.HitPointComponent{Hp: 5, MaxHP: 5},
+			"block":      ecs.BlockingComponent{},
+			"reproducer": ecs.ReproducesComponent{MaxTimes: 8, TimesRemaining: 8, PercentChance: 25},
+			"faction":    ecs.FactionComponent{Name: "fungi"},
+			"killable":   ecs.KillableComponent{Name: "Remains of", Color: "yellow", Character: "."}})
+	}
+
+	return createdEntity
+}
+
+func createTrap(x, y, depth int) *ecs.GameEntity {
+	// Create a random, hidden, trap at the given location. Deeper traps are harder to find, and more likely to be the
+	// nastier kinds.
+	var kind, name, color, description string
+
+	chance := rand.Intn(100) + depth*2
+
+	if chance < 40 {
+		kind, name, color = "pit", "Pit Trap", "dark amber"
+		description = "A hole in the floor, hidden under a thin layer of dirt. Falling in would hurt."
+	} else if chance < 70 {
+		kind, name, color = "dart", "Dart Trap", "light gray"
+		description = "A pressure plate, wired up to a dart launcher in the wall."
+	} else if chance < 90 {
+		kind, name, color = "alarm", "Alarm Trap", "yellow"
+		description = "A tripwire, tied to a collection of bells. Anything nearby will hear it go off."
+	} else {
+		kind, name, color = "teleport", "Teleport Trap", "light violet"
+		description = "A faintly glowing rune, scratched into the floor. Stepping on it would send you somewhere else."
+	}
+
+	trap := &ecs.GameEntity{}
+	trap.SetupGameEntity()
+	trap.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+		"appearance":  ecs.AppearanceComponent{Layer: TrapLayer, Character: "^", Color: color, Name: name},
+		"trap":        ecs.TrapComponent{Kind: kind, Hidden: true, Difficulty: rand.Intn(10) + depth*3},
+		"description": ecs.DescriptionComponent{ShortDesc: description}})
+
+	return trap
+}
+
+func createBrazier(x, y int) *ecs.GameEntity {
+	// Create a brazier, which lights up the area around it
+	brazier := &ecs.GameEntity{}
+	brazier.SetupGameEntity()
+	brazier.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+		"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "&", Color: "flame", Name: "Brazier"},
+		"block":       ecs.BlockingComponent{},
+		"light":       ecs.LightComponent{Radius: 6, Color: "flame"},
+		"description": ecs.DescriptionComponent{ShortDesc: "An iron bowl of burning coals, on a heavy stand. It lights up the whole room."}})
+
+	return brazier
+}
+
+func createKey(x, y int) *ecs.GameEntity {
+	// Create a key, which will unlock any one locked door
+	key := &ecs.GameEntity{}
+	key.SetupGameEntity()
+	key.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+		"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "-", Color: "yellow", Name: "Iron Key"},
+		"lootable":    ecs.LootableComponent{InInventory: false, ID: 5},
+		"stackable":   ecs.StackableComponent{},
+		"key":         ecs.KeyComponent{},
+		"description": ecs.DescriptionComponent{ShortDesc: "A heavy iron key. It should fit any lock down here, but only once."}})
+
+	return key
+}
+
+func createItem(x, y int) *ecs.GameEntity {
+	// Create a random item at the given location
+	var createdEntity *ecs.GameEntity
+
+	chance := rand.Intn(100)
+
+	if chance < 8 {
+		// Create a scroll that calls forth a spirit wolf to fight alongside the player
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "?", Color: "light blue", Name: "Scroll of Summoning"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 4},
+			"stackable":   ecs.StackableComponent{},
+			"usable":      ecs.UsableComponent{Effect: "summon_ally", Power: 1},
+			"description": ecs.DescriptionComponent{ShortDesc: "A scroll covered in drawings of wolves. Use it (a) to call for aid."}})
+	} else if chance < 20 {
+		// Create a small bundle of throwing darts
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: ")", Color: "light gray", Name: "Throwing Dart"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 3},
+			"stackable":   ecs.StackableComponent{},
+			"throwable":   ecs.ThrowableComponent{Damage: 4, Range: 8},
+			"description": ecs.DescriptionComponent{ShortDesc: "A small, weighted dart. Made for throwing (t)."}})
+	} else if chance < 24 {
+		// Create a scroll that blasts a tunnel through solid rock
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "?", Color: "light amber", Name: "Scroll of Tunneling"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 6},
+			"stackable":   ecs.StackableComponent{},
+			"usable":      ecs.UsableComponent{Effect: "tunnel", Power: 8},
+			"description": ecs.DescriptionComponent{ShortDesc: "A scroll, gritty with rock dust. Use it (a), and pick a direction, to tunnel through the walls."}})
+	} else if chance < 27 {
+		// Create a pickaxe, for digging through walls
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "(", Color: "light gray", Name: "Pickaxe"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 7},
+			"digger":      ecs.DiggerComponent{Speed: 1},
+			"description": ecs.DescriptionComponent{ShortDesc: "A well worn miner's pickaxe. Walk into a wall while carrying it to dig through."}})
+	} else if chance < 30 {
+		// Create a torch, which lights up the area around it, whether it is carried or left on the floor
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "/", Color: "amber", Name: "Torch"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 8},
+			"light":       ecs.LightComponent{Radius: 4, Color: "amber"},
+			"description": ecs.DescriptionComponent{ShortDesc: "A burning torch. Drop it (d), or throw it (t), to light up somewhere else."}})
+	} else if chance < 33 {
+		// Create a scroll that reveals the layout of the level around the player
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "?", Color: "light violet", Name: "Scroll of Magic Mapping"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 9},
+			"stackable":   ecs.StackableComponent{},
+			"usable":      ecs.UsableComponent{Effect: "magic_mapping", Power: 40},
+			"description": ecs.DescriptionComponent{ShortDesc: "A scroll covered in a fine, twisting map. Use it (a) to learn the lay of the land."}})
+	} else if chance < 36 {
+		// Create a potion that lets the player sense every creature on the level for a while
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "!", Color: "light violet", Name: "Potion of Telepathy"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 10},
+			"stackable":   ecs.StackableComponent{},
+			"usable":      ecs.UsableComponent{Effect: "detect_monsters", Power: 30},
+			"description": ecs.DescriptionComponent{ShortDesc: "A swirling, violet potion. Drink it (a) to sense the minds around you."}})
+	} else if chance < 39 {
+		// Create a scroll that reveals the items lying around the player
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "?", Color: "yellow", Name: "Scroll of Treasure Finding"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 11},
+			"stackable":   ecs.StackableComponent{},
+			"usable":      ecs.UsableComponent{Effect: "detect_items", Power: 40},
+			"description": ecs.DescriptionComponent{ShortDesc: "A scroll, edged in gold leaf. Use it (a) to sense any treasure nearby."}})
+	} else if chance >= 49 {
+		// Create a healing potion
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "!", Color: "dark red", Name: "Dark Red Potion"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 1},
+			"stackable":   ecs.StackableComponent{},
+			"description": ecs.DescriptionComponent{ShortDesc: "An unmarked, single dose, vial of a dark red liquid."}})
+	} else {
+		// Create a healing potion
+		createdEntity = &ecs.GameEntity{}
+		createdEntity.SetupGameEntity()
+		createdEntity.AddComponents(map[string]ecs.Component{"position": ecs.PositionComponent{X: x, Y: y},
+			"appearance":  ecs.AppearanceComponent{Layer: ItemLayer, Character: "!", Color: "light green", Name: "Bright Green Potion"},
+			"lootable":    ecs.LootableComponent{InInventory: false, ID: 2},
+			"stackable":   ecs.StackableComponent{},
+			"description": ecs.DescriptionComponent{ShortDesc: "An unmarked, single dose, vial of a bright green liquid."}})
+	}
+
+	return createdEntity
+}
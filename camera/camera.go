@@ -9,9 +9,16 @@ type GameCamera struct {
 
 func (c *GameCamera) MoveCamera(targetX int, targetY int, mapWidth int, mapHeight int) {
 	// Update the camera coordinates to the target coordinates
-	x := targetX - c.Width/2
-	y := targetY - c.Height/2
+	c.moveTo(targetX-c.Width/2, targetY-c.Height/2, mapWidth, mapHeight)
+}
+
+func (c *GameCamera) Pan(dx int, dy int, mapWidth int, mapHeight int) {
+	// Shift the camera by the given amount, without going past the edge of the map
+	c.moveTo(c.X+dx, c.Y+dy, mapWidth, mapHeight)
+}
 
+func (c *GameCamera) moveTo(x int, y int, mapWidth int, mapHeight int) {
+	// Move the top left corner of the camera to the given coordinates, keeping the whole view on the map
 	if x < 0 {
 		x = 0
 	}
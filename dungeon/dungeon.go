@@ -0,0 +1,54 @@
+package dungeon
+
+import (
+	"bearrogue/ecs"
+	"bearrogue/gamemap"
+)
+
+type Level struct {
+	Depth    int
+	Map      *gamemap.Map
+	Entities []*ecs.GameEntity
+}
+
+type Dungeon struct {
+	Levels  []*Level
+	Current int
+}
+
+func (d *Dungeon) CurrentLevel() *Level {
+	// Return the level the player is currently on
+	if d.Current < 0 || d.Current >= len(d.Levels) {
+		return nil
+	}
+	return d.Levels[d.Current]
+}
+
+func (d *Dungeon) AddLevel(level *Level) {
+	// Add a new level to the bottom of the dungeon
+	d.Levels = append(d.Levels, level)
+}
+
+func (d *Dungeon) HasLevel(index int) bool {
+	return index >= 0 && index < len(d.Levels)
+}
+
+func (d *Dungeon) ChangeLevel(index int, travellers []*ecs.GameEntity, remaining []*ecs.GameEntity) *Level {
+	// Move from the current level to the level at the given index. Any entities left behind (remaining) are stored
+	// with the level being left, so they are still there when the player returns. The travellers are added to the
+	// new level, in front of whatever is already there. The map of each level is kept as is, so anything the player
+	// has explored stays explored.
+	if !d.HasLevel(index) {
+		return nil
+	}
+
+	if current := d.CurrentLevel(); current != nil {
+		current.Entities = remaining
+	}
+
+	d.Current = index
+	next := d.Levels[index]
+	next.Entities = append(travellers, next.Entities...)
+
+	return next
+}
@@ -0,0 +1,263 @@
+package ecs
+
+import (
+	"bearrogue/camera"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"sort"
+)
+
+// An AIBehavior decides what an entity does on its turn. Act returns true if the entity did something, and false if
+// the behavior had nothing to do, which lets composite behaviors move on to their next option.
+type AIBehavior interface {
+	Act(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool
+}
+
+// AIBehaviorFunc allows a plain function to be used as an AIBehavior
+type AIBehaviorFunc func(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool
+
+func (f AIBehaviorFunc) Act(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	return f(entity, entities, gameMap, camera, messageLog)
+}
+
+// PrioritizedBehavior tries each of its behaviors in order, and stops at the first one that acts
+type PrioritizedBehavior []AIBehavior
+
+func (p PrioritizedBehavior) Act(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	for _, behavior := range p {
+		if behavior.Act(entity, entities, gameMap, camera, messageLog) {
+			return true
+		}
+	}
+	return false
+}
+
+// UtilityOption pairs a behavior with a function scoring how useful it would be right now
+type UtilityOption struct {
+	Score    func(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map) int
+	Behavior AIBehavior
+}
+
+// UtilityBehavior scores each of its options, and tries them from the highest score to the lowest, stopping at the
+// first one that acts. Options with a score of 0 or less are never chosen. Ties go to whichever option was listed first.
+type UtilityBehavior []UtilityOption
+
+func (u UtilityBehavior) Act(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	scores := make([]int, len(u))
+	order := make([]int, len(u))
+
+	for i, option := range u {
+		scores[i] = option.Score(entity, entities, gameMap)
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	for _, i := range order {
+		if scores[i] > 0 && u[i].Behavior.Act(entity, entities, gameMap, camera, messageLog) {
+			return true
+		}
+	}
+	return false
+}
+
+type registeredBehavior struct {
+	priority int
+	behavior AIBehavior
+}
+
+var aiBehaviors = map[string]registeredBehavior{}
+
+func RegisterAIBehavior(name string, priority int, behavior AIBehavior) {
+	// Register a behavior under the name of the AI component that uses it. If an entity has more than one AI component,
+	// the one with the highest priority is used.
+	aiBehaviors[name] = registeredBehavior{priority: priority, behavior: behavior}
+}
+
+func GetAIBehavior(name string) AIBehavior {
+	// Return the behavior registered under the given name, or nil if there is none
+	if registered, ok := aiBehaviors[name]; ok {
+		return registered.behavior
+	}
+	return nil
+}
+
+func aiPriority(name string) int {
+	if registered, ok := aiBehaviors[name]; ok {
+		return registered.priority
+	}
+	return -1
+}
+
+func init() {
+	RegisterAIBehavior("random_movement", 0, AIBehaviorFunc(actWander))
+
+	// The melee and ranged AIs share everything except how they deal with a target they can see
+	RegisterAIBehavior("basic_melee_ai", 10, PrioritizedBehavior{
+		AIBehaviorFunc(actAsleep),
+		AIBehaviorFunc(actMeleeAttack),
+		AIBehaviorFunc(actFollowLeader),
+		AIBehaviorFunc(actInvestigate),
+		AIBehaviorFunc(actWander),
+	})
+
+	RegisterAIBehavior("ranged_ai", 20, PrioritizedBehavior{
+		AIBehaviorFunc(actAsleep),
+		AIBehaviorFunc(actRangedAttack),
+		AIBehaviorFunc(actFollowLeader),
+		AIBehaviorFunc(actInvestigate),
+		AIBehaviorFunc(actWander),
+	})
+}
+
+func acquireTarget(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) (*GameEntity, PerceptionComponent) {
+	// Pick the closest hostile entity in sight as the target (this will usually be the player, but not always), and
+	// update what the entity knows about it. Followers take their orders into account when choosing what to fight.
+	target := findNearestHostile(entity, entities, gameMap)
+
+	if entity.HasComponent("follower") {
+		target = selectFollowerTarget(entity, target)
+	}
+
+	// The entity uses its own eyes for this, rather than the players field of vision
+	perception := SystemPerception(entity, target, gameMap, messageLog)
+
+	if perception.State != Alert {
+		target = nil
+	}
+
+	return target, perception
+}
+
+func actAsleep(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// Sleeping entities do nothing until something wakes them up
+	return getPerception(entity).State == Asleep
+}
+
+func actMeleeAttack(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// The entity will move towards its target until it is right next to it, then it will repeatedly attack the target
+	if !entity.HasComponents([]string{"position", "movement", "appearance", "basic_melee_ai"}) {
+		return false
+	}
+
+	target, _ := acquireTarget(entity, entities, gameMap, messageLog)
+
+	if target == nil {
+		return false
+	}
+
+	appearanceComponent, _ := entity.Components["appearance"].(AppearanceComponent)
+	positionComponent, _ := entity.Components["position"].(PositionComponent)
+	basicMeleeAi, _ := entity.Components["basic_melee_ai"].(BasicMeleeAIComponent)
+	targetPositionComponent, _ := target.Components["position"].(PositionComponent)
+
+	oldTarget := basicMeleeAi.target
+
+	// Set the target
+	basicMeleeAi.target = target
+
+	if oldTarget != basicMeleeAi.target && gameMap.IsVisibleToPlayer(positionComponent.X, positionComponent.Y) {
+		targetAppearanceComponent, _ := basicMeleeAi.target.Components["appearance"].(AppearanceComponent)
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "The "+appearanceComponent.ColoredName()+" throws an angry glare at "+targetAppearanceComponent.ColoredName()+"!")
+	}
+
+	entity.RemoveComponent("basic_melee_ai")
+	entity.AddComponent("basic_melee_ai", basicMeleeAi)
+
+	// Now that the entity has a target, move towards it
+	moveTowards(entity, targetPositionComponent.X, targetPositionComponent.Y, entities, gameMap, messageLog)
+
+	return true
+}
+
+func actRangedAttack(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// A ranged attacker tries to keep its preferred distance from its target, and fires at it whenever it has a clear
+	// line of fire. Entities without a movement component (like a spitting fungus) stay put, and only fire.
+	if !entity.HasComponents([]string{"position", "appearance", "ranged_ai"}) {
+		return false
+	}
+
+	target, _ := acquireTarget(entity, entities, gameMap, messageLog)
+
+	if target == nil {
+		return false
+	}
+
+	rangedAi, _ := entity.Components["ranged_ai"].(RangedAIComponent)
+	positionComponent, _ := entity.Components["position"].(PositionComponent)
+	targetPositionComponent, _ := target.Components["position"].(PositionComponent)
+	canMove := entity.HasComponent("movement")
+
+	distance := distanceTo(positionComponent.X, positionComponent.Y, targetPositionComponent.X, targetPositionComponent.Y)
+
+	if canMove && distance < rangedAi.PreferredDistance && moveAwayFrom(entity, targetPositionComponent.X, targetPositionComponent.Y, entities, gameMap, messageLog) {
+		// Backed off a step, that is this turns action
+		return true
+	}
+
+	if hasLineOfFire(entity, targetPositionComponent.X, targetPositionComponent.Y, rangedAi.Range, entities, gameMap) {
+		if gameMap.IsVisibleToPlayer(positionComponent.X, positionComponent.Y) {
+			app, _ := entity.Components["appearance"].(AppearanceComponent)
+			messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "The "+app.ColoredName()+" fires "+rangedAi.ProjectileName+"!")
+		}
+
+		hit, _, _ := SystemProjectile(entity, targetPositionComponent.X, targetPositionComponent.Y, rangedAi.Range, rangedAi.Projectile, rangedAi.ProjectileColor, entities, gameMap, camera)
+
+		if hit != nil {
+			SystemAttack(entity, hit, entities, gameMap, messageLog)
+		}
+	} else if canMove {
+		// No clear shot, so try and get closer
+		moveTowards(entity, targetPositionComponent.X, targetPositionComponent.Y, entities, gameMap, messageLog)
+	}
+
+	// Even without a shot, an immobile shooter with a target in sight just waits for one
+	return true
+}
+
+func actFollowLeader(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// Followers with nothing to fight stick close to their leader, rather than wandering off
+	if !entity.HasComponent("follower") {
+		return false
+	}
+
+	SystemFollow(entity, entities, gameMap, messageLog)
+	return true
+}
+
+func actInvestigate(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// The entity cannot see a target, but it knows (or heard) where one was. Go and take a look.
+	perception := getPerception(entity)
+
+	if perception.State != Alert || !perception.HasLastKnown || !entity.HasComponents([]string{"position", "movement"}) {
+		return false
+	}
+
+	positionComponent, _ := entity.Components["position"].(PositionComponent)
+
+	if positionComponent.X == perception.LastKnownX && positionComponent.Y == perception.LastKnownY {
+		// Nothing here, lose interest, and let the next behavior decide what to do
+		perception.State = Unaware
+		perception.HasLastKnown = false
+
+		entity.RemoveComponent("perception")
+		entity.AddComponent("perception", perception)
+
+		return false
+	}
+
+	moveTowards(entity, perception.LastKnownX, perception.LastKnownY, entities, gameMap, messageLog)
+	return true
+}
+
+func actWander(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+	// The entity is not aware of anything, so it should just shuffle around randomly
+	if !entity.HasComponents([]string{"movement", "position"}) {
+		return false
+	}
+
+	SystemRandomMovement(entity, entities, gameMap, messageLog)
+	return true
+}
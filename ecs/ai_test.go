@@ -0,0 +1,135 @@
+package ecs
+
+import (
+	"bearrogue/camera"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"testing"
+)
+
+// An AI component that can go by any name, for registering behaviors only the tests know about
+type testAIComponent struct{}
+
+func (t testAIComponent) IsAIComponent() bool {
+	return true
+}
+
+func TestHasAIComponentPriority(t *testing.T) {
+	RegisterAIBehavior("test_ai_b", 15, AIBehaviorFunc(actWander))
+	RegisterAIBehavior("test_ai_a", 15, AIBehaviorFunc(actWander))
+	defer delete(aiBehaviors, "test_ai_a")
+	defer delete(aiBehaviors, "test_ai_b")
+
+	tests := []struct {
+		name       string
+		components []string
+		expect     string
+	}{
+		{"no AI at all", nil, ""},
+		{"a single AI", []string{"random_movement"}, "random_movement"},
+		{"melee beats wandering", []string{"random_movement", "basic_melee_ai"}, "basic_melee_ai"},
+		{"ranged beats melee", []string{"basic_melee_ai", "ranged_ai", "random_movement"}, "ranged_ai"},
+		{"registered beats unregistered", []string{"test_ai_unregistered", "random_movement"}, "random_movement"},
+		{"ties go to the first name", []string{"test_ai_b", "test_ai_a", "basic_melee_ai"}, "test_ai_a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Go's map ordering is random, so check a few times over to be sure the answer never changes
+			for i := 0; i < 20; i++ {
+				entity := newEntity(map[string]Component{"position": PositionComponent{}})
+				for _, name := range test.components {
+					entity.AddComponent(name, testAIComponent{})
+				}
+
+				if chosen := entity.HasAIComponent(); chosen != test.expect {
+					t.Fatalf("expected %q, got %q", test.expect, chosen)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAIBehavior(t *testing.T) {
+	for _, name := range []string{"random_movement", "basic_melee_ai", "ranged_ai"} {
+		if GetAIBehavior(name) == nil {
+			t.Errorf("expected a behavior to be registered for %q", name)
+		}
+	}
+
+	if GetAIBehavior("no_such_ai") != nil {
+		t.Error("expected no behavior for an unregistered name")
+	}
+}
+
+// recorder returns a behavior that notes down that it was tried, and acts if told to
+func recorder(name string, acts bool, tried *[]string) AIBehavior {
+	return AIBehaviorFunc(func(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) bool {
+		*tried = append(*tried, name)
+		return acts
+	})
+}
+
+func score(value int) func(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map) int {
+	return func(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map) int {
+		return value
+	}
+}
+
+func TestPrioritizedBehavior(t *testing.T) {
+	var tried []string
+
+	behavior := PrioritizedBehavior{
+		recorder("first", false, &tried),
+		recorder("second", true, &tried),
+		recorder("third", true, &tried),
+	}
+
+	if !behavior.Act(nil, nil, nil, nil, nil) {
+		t.Error("expected the behavior to act")
+	}
+
+	if len(tried) != 2 || tried[0] != "first" || tried[1] != "second" {
+		t.Errorf("expected the first two behaviors to be tried, in order, got %v", tried)
+	}
+}
+
+func TestUtilityBehavior(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []int
+		acts   []bool
+		expect []string
+	}{
+		{"highest score first", []int{1, 5, 3}, []bool{true, true, true}, []string{"b"}},
+		{"falls back to the next best", []int{1, 5, 3}, []bool{true, false, true}, []string{"b", "c"}},
+		{"ties go to the first listed", []int{4, 4, 4}, []bool{true, true, true}, []string{"a"}},
+		{"nothing scored above zero", []int{0, -2, 0}, []bool{true, true, true}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tried []string
+			var behavior UtilityBehavior
+
+			for i, name := range []string{"a", "b", "c"} {
+				behavior = append(behavior, UtilityOption{Score: score(test.scores[i]), Behavior: recorder(name, test.acts[i], &tried)})
+			}
+
+			acted := behavior.Act(nil, nil, nil, nil, nil)
+
+			if acted != (len(test.expect) > 0) {
+				t.Errorf("expected acting to be %v", len(test.expect) > 0)
+			}
+
+			if len(tried) != len(test.expect) {
+				t.Fatalf("expected %v to be tried, got %v", test.expect, tried)
+			}
+			for i := range tried {
+				if tried[i] != test.expect[i] {
+					t.Errorf("expected %v to be tried, got %v", test.expect, tried)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+const (
+	// Orders that can be given to followers
+	OrderFollow = iota
+	OrderStay
+	OrderAttack
+)
+
+const (
+	FollowDistance = 2
+)
+
+func MakeFollower(entity, leader *GameEntity) {
+	// Turn the entity into a follower of the leader. It joins the leaders faction, and is immediately on the lookout for
+	// anything hostile.
+	perception := getPerception(entity)
+	perception.State = Alert
+	perception.HasLastKnown = false
+
+	entity.RemoveComponents([]string{"follower", "recruitable", "faction", "perception"})
+
+	components := map[string]Component{"follower": FollowerComponent{Leader: leader, Order: OrderFollow}, "perception": perception}
+
+	if leader.HasComponent("faction") {
+		components["faction"] = leader.Components["faction"]
+	}
+
+	entity.AddComponents(components)
+}
+
+func SystemRecruit(entity, target *GameEntity, messageLog *ui.MessageLog) {
+	// The entity attempts to recruit the target into its service. This only works on entities that are willing to be
+	// recruited, by entities of the faction they are willing to join.
+	if !target.HasComponents([]string{"recruitable", "appearance"}) || !entity.HasComponent("faction") {
+		return
+	}
+
+	recruitable, _ := target.Components["recruitable"].(RecruitableComponent)
+	faction, _ := entity.Components["faction"].(FactionComponent)
+	app, _ := target.Components["appearance"].(AppearanceComponent)
+
+	if recruitable.Faction != "" && recruitable.Faction != faction.Name {
+		return
+	}
+
+	MakeFollower(target, entity)
+
+	if entity.HasComponent("player") {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityGood, "The "+app.ColoredName()+" decides to join you!")
+	}
+}
+
+func GetFollowers(leader *GameEntity, entities []*GameEntity) []*GameEntity {
+	// Return every living follower of the leader
+	followers := []*GameEntity{}
+
+	for _, e := range entities {
+		if e != nil && e.HasComponents([]string{"follower", "hitpoints"}) {
+			follower, _ := e.Components["follower"].(FollowerComponent)
+
+			if follower.Leader == leader {
+				followers = append(followers, e)
+			}
+		}
+	}
+
+	return followers
+}
+
+func SystemOrderFollowers(leader *GameEntity, order int, target *GameEntity, entities []*GameEntity, messageLog *ui.MessageLog) {
+	// Give an order to each of the leaders followers. The target is only used for attack orders.
+	followers := GetFollowers(leader, entities)
+
+	if len(followers) == 0 {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You have no allies to give orders to.")
+		return
+	}
+
+	if order == OrderAttack && (target == nil || !target.HasComponents([]string{"hitpoints", "position"})) {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "There is nothing there to attack.")
+		return
+	}
+
+	for _, e := range followers {
+		follower, _ := e.Components["follower"].(FollowerComponent)
+		follower.Order = order
+		follower.Target = target
+
+		e.RemoveComponent("follower")
+		e.AddComponent("follower", follower)
+	}
+
+	switch order {
+	case OrderFollow:
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You order your allies to follow you.")
+	case OrderStay:
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You order your allies to stay where they are.")
+	case OrderAttack:
+		app, _ := target.Components["appearance"].(AppearanceComponent)
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You order your allies to attack the "+app.ColoredName()+"!")
+	}
+}
+
+func selectFollowerTarget(entity *GameEntity, nearestHostile *GameEntity) *GameEntity {
+	// Followers pick their targets based on their current orders. An attack order overrides whatever is closest, and a
+	// follower told to stay will only fight things that come right up to it.
+	follower, _ := entity.Components["follower"].(FollowerComponent)
+
+	switch follower.Order {
+	case OrderAttack:
+		if follower.Target != nil && follower.Target.HasComponents([]string{"hitpoints", "position"}) {
+			return follower.Target
+		}
+
+		// The target is dead (or gone), go back to following the leader
+		follower.Order = OrderFollow
+		follower.Target = nil
+
+		entity.RemoveComponent("follower")
+		entity.AddComponent("follower", follower)
+	case OrderStay:
+		if nearestHostile != nil {
+			pos, _ := entity.Components["position"].(PositionComponent)
+			targetPos, _ := nearestHostile.Components["position"].(PositionComponent)
+
+			if distanceTo(pos.X, pos.Y, targetPos.X, targetPos.Y) > 1 {
+				return nil
+			}
+		}
+	}
+
+	return nearestHostile
+}
+
+func SystemFollow(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// A follower with nothing to fight keeps close to its leader, unless it has been told to stay put
+	if !entity.HasComponents([]string{"follower", "position", "movement"}) {
+		return
+	}
+
+	follower, _ := entity.Components["follower"].(FollowerComponent)
+
+	if follower.Order == OrderStay || follower.Leader == nil || !follower.Leader.HasComponent("position") {
+		return
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	leaderPos, _ := follower.Leader.Components["position"].(PositionComponent)
+
+	if distanceTo(pos.X, pos.Y, leaderPos.X, leaderPos.Y) > FollowDistance {
+		moveTowards(entity, leaderPos.X, leaderPos.Y, entities, gameMap, messageLog)
+	}
+}
+
+func swapPlaces(entity, other *GameEntity) {
+	// Swap the positions of two entities, such as the player and one of their allies
+	pos, _ := entity.Components["position"].(PositionComponent)
+	otherPos, _ := other.Components["position"].(PositionComponent)
+
+	entity.RemoveComponent("position")
+	entity.AddComponent("position", otherPos)
+
+	other.RemoveComponent("position")
+	other.AddComponent("position", pos)
+}
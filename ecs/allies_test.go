@@ -0,0 +1,107 @@
+package ecs
+
+import "testing"
+
+func TestSystemRecruit(t *testing.T) {
+	tests := []struct {
+		name    string
+		faction string
+		expect  bool
+	}{
+		{"willing to join anyone", "", true},
+		{"willing to join the player", "player", true},
+		{"only willing to join someone else", "orcs", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := newEntity(map[string]Component{"player": PlayerComponent{}, "faction": FactionComponent{Name: "player"}})
+			dog := newEntity(map[string]Component{
+				"appearance":  AppearanceComponent{Name: "Cave Dog"},
+				"recruitable": RecruitableComponent{Faction: test.faction},
+				"faction":     FactionComponent{Name: "strays"},
+				"hitpoints":   HitPointComponent{Hp: 5, MaxHP: 5},
+			})
+
+			SystemRecruit(player, dog, newMessageLog())
+
+			if recruited := len(GetFollowers(player, []*GameEntity{player, dog})) == 1; recruited != test.expect {
+				t.Fatalf("expected recruiting to be %v, got %v", test.expect, recruited)
+			}
+
+			if test.expect {
+				if dog.HasComponent("recruitable") {
+					t.Error("expected the dog to no longer be recruitable")
+				}
+				if GetRelationship(dog, player) != Allied {
+					t.Error("expected the dog to join the players faction")
+				}
+			}
+		})
+	}
+}
+
+func TestSelectFollowerTarget(t *testing.T) {
+	near := newEntity(map[string]Component{"position": PositionComponent{X: 6, Y: 5}, "hitpoints": HitPointComponent{Hp: 1}})
+	far := newEntity(map[string]Component{"position": PositionComponent{X: 9, Y: 5}, "hitpoints": HitPointComponent{Hp: 1}})
+	ordered := newEntity(map[string]Component{"position": PositionComponent{X: 2, Y: 2}, "hitpoints": HitPointComponent{Hp: 1}})
+	dead := newEntity(map[string]Component{"position": PositionComponent{X: 2, Y: 2}})
+
+	tests := []struct {
+		name        string
+		order       int
+		target      *GameEntity
+		nearest     *GameEntity
+		expect      *GameEntity
+		expectOrder int
+	}{
+		{"following, fights whatever is closest", OrderFollow, nil, far, far, OrderFollow},
+		{"staying, fights what is right next to it", OrderStay, nil, near, near, OrderStay},
+		{"staying, ignores what is further away", OrderStay, nil, far, nil, OrderStay},
+		{"attacking, goes after its target", OrderAttack, ordered, near, ordered, OrderAttack},
+		{"attacking a dead target, goes back to following", OrderAttack, dead, near, near, OrderFollow},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ally := newEntity(map[string]Component{
+				"position": PositionComponent{X: 5, Y: 5},
+				"follower": FollowerComponent{Order: test.order, Target: test.target},
+			})
+
+			if target := selectFollowerTarget(ally, test.nearest); target != test.expect {
+				t.Error("got the wrong target")
+			}
+
+			if follower, _ := ally.Components["follower"].(FollowerComponent); follower.Order != test.expectOrder {
+				t.Errorf("expected order %d, got %d", test.expectOrder, follower.Order)
+			}
+		})
+	}
+}
+
+func TestDeadFollowerIsForgotten(t *testing.T) {
+	gameMap := openMap(10, 10)
+	player := newEntity(map[string]Component{"player": PlayerComponent{}, "faction": FactionComponent{Name: "player"}})
+	ally := newEntity(map[string]Component{
+		"position":       PositionComponent{X: 5, Y: 5},
+		"appearance":     AppearanceComponent{Name: "Goblin"},
+		"hitpoints":      HitPointComponent{Hp: 3, MaxHP: 3},
+		"killable":       KillableComponent{Name: "Dead", Character: "%", Color: "red"},
+		"basic_melee_ai": BasicMeleeAIComponent{},
+		"perception":     PerceptionComponent{SightRadius: 8, State: Alert},
+	})
+	MakeFollower(ally, player)
+
+	applyDamage(player, ally, 10, gameMap, newMessageLog())
+
+	for _, component := range []string{"follower", "basic_melee_ai", "perception", "faction", "hitpoints"} {
+		if ally.HasComponent(component) {
+			t.Errorf("expected the corpse to have no %s component", component)
+		}
+	}
+
+	if len(GetFollowers(player, []*GameEntity{player, ally})) != 0 {
+		t.Error("expected the player to have no followers left")
+	}
+}
@@ -1,5 +1,11 @@
 package ecs
 
+import (
+	"bearrogue/fov"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
 type Component interface {
 	IsAIComponent() bool
 }
@@ -34,8 +40,14 @@ func (a AppearanceComponent) IsAIComponent() bool {
 	return false
 }
 
+func (a AppearanceComponent) ColoredName() string {
+	// The entities name, marked up to be printed in its own color
+	return ui.Colored(a.Name, a.Color)
+}
+
 // Movement Component
 type MovementComponent struct {
+	CanOpenDoors bool
 }
 
 func (m MovementComponent) IsAIComponent() bool {
@@ -94,6 +106,19 @@ func (b BasicMeleeAIComponent) IsAIComponent() bool {
 	return true
 }
 
+// Ranged AI Component - keeps its distance from its target, and attacks with projectiles when it has a clear shot
+type RangedAIComponent struct {
+	Range             int
+	PreferredDistance int
+	Projectile        string
+	ProjectileColor   string
+	ProjectileName    string
+}
+
+func (r RangedAIComponent) IsAIComponent() bool {
+	return true
+}
+
 // Reproduces Component
 type ReproducesComponent struct {
 	MaxTimes       int
@@ -155,3 +180,167 @@ type DescriptionComponent struct {
 func (d DescriptionComponent) IsAIComponent() bool {
 	return false
 }
+
+// Perception Component - how an entity notices what is going on around it
+type PerceptionComponent struct {
+	SightRadius  int
+	Hearing      int
+	State        int
+	LastKnownX   int
+	LastKnownY   int
+	HasLastKnown bool
+}
+
+func (p PerceptionComponent) IsAIComponent() bool {
+	return false
+}
+
+// Sneaking Component - the entity is moving quietly, and is harder to notice
+type SneakingComponent struct {
+}
+
+func (s SneakingComponent) IsAIComponent() bool {
+	return false
+}
+
+// Running Component - the entity is moving in a hurry, and making a lot of noise about it
+type RunningComponent struct {
+}
+
+func (r RunningComponent) IsAIComponent() bool {
+	return false
+}
+
+// Throwable Component - an item that is made to be thrown, and hurts whatever it hits
+type ThrowableComponent struct {
+	Damage int
+	Range  int
+}
+
+func (t ThrowableComponent) IsAIComponent() bool {
+	return false
+}
+
+// Faction Component - which side the entity is on. See faction.go for how factions feel about one another.
+type FactionComponent struct {
+	Name string
+}
+
+func (f FactionComponent) IsAIComponent() bool {
+	return false
+}
+
+// Follower Component - the entity is an ally of its leader, follows them around, and carries out their orders
+type FollowerComponent struct {
+	Leader *GameEntity
+	Order  int
+	Target *GameEntity
+}
+
+func (f FollowerComponent) IsAIComponent() bool {
+	return false
+}
+
+// Recruitable Component - the entity will join whoever bumps into it, if they are friendly enough
+type RecruitableComponent struct {
+	Faction string
+}
+
+func (r RecruitableComponent) IsAIComponent() bool {
+	return false
+}
+
+// Usable Component - an item that does something when used (applied, read, quaffed, etc)
+type UsableComponent struct {
+	Effect string
+	Power  int
+}
+
+func (u UsableComponent) IsAIComponent() bool {
+	return false
+}
+
+// Slowed Component - the entity is struggling through difficult terrain, and loses its next few turns
+type SlowedComponent struct {
+	Turns int
+}
+
+func (s SlowedComponent) IsAIComponent() bool {
+	return false
+}
+
+// Key Component - an item that can unlock a locked door. The key is used up when it is.
+type KeyComponent struct {
+}
+
+func (k KeyComponent) IsAIComponent() bool {
+	return false
+}
+
+// Trap Component - the entity is a trap, which goes off when something steps on it. Hidden traps cannot be seen until
+// they have been found (by searching, or by stepping on them).
+type TrapComponent struct {
+	Kind       string
+	Hidden     bool
+	Difficulty int
+}
+
+func (t TrapComponent) IsAIComponent() bool {
+	return false
+}
+
+// Path Component - the path the entity is currently following, kept so it does not have to be found again every turn.
+// It is thrown away once the target moves, or the map changes along the way.
+type PathComponent struct {
+	Steps    []*gamemap.Tile
+	TargetX  int
+	TargetY  int
+	Map      *gamemap.Map
+	Revision int
+}
+
+func (p PathComponent) IsAIComponent() bool {
+	return false
+}
+
+// Digger Component - a tool that can dig through walls. Speed is how much progress is made with each turn of digging.
+type DiggerComponent struct {
+	Speed int
+}
+
+func (d DiggerComponent) IsAIComponent() bool {
+	return false
+}
+
+// Digging Component - the entity is partway through digging out a wall. Tunnel holds the walls still to be cleared by
+// a magical tunnel, which carries on by itself, a few tiles each turn.
+type DiggingComponent struct {
+	X        int
+	Y        int
+	Progress int
+	Tunnel   []fov.Point
+}
+
+func (d DiggingComponent) IsAIComponent() bool {
+	return false
+}
+
+// Light Component - the entity gives off light, out to the given radius. Items that give off light still do while they
+// are being carried.
+type LightComponent struct {
+	Radius int
+	Color  string
+}
+
+func (l LightComponent) IsAIComponent() bool {
+	return false
+}
+
+// Telepathic Component - the entity can sense the minds of every creature around it, even through walls
+type TelepathicComponent struct {
+	Turns int
+}
+
+func (t TelepathicComponent) IsAIComponent() bool {
+	return false
+}
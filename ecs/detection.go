@@ -0,0 +1,146 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"strconv"
+)
+
+func effectMagicMapping(entity *GameEntity, radius int, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Reveal the layout of the map around the entity, out to the given radius. Open ground, and any wall next to it,
+	// is marked as mapped. Mapped tiles are drawn, but they have not actually been seen, so nothing on them is shown.
+	if !entity.HasComponent("position") {
+		return false
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+
+	for x := pos.X - radius; x <= pos.X+radius; x++ {
+		for y := pos.Y - radius; y <= pos.Y+radius; y++ {
+			if x < 0 || y < 0 || x >= gameMap.Width || y >= gameMap.Height || distanceTo(pos.X, pos.Y, x, y) > radius {
+				continue
+			}
+
+			if !gameMap.Tiles[x][y].IsWall() || nextToOpenGround(x, y, gameMap) {
+				gameMap.Tiles[x][y].Mapped = true
+			}
+		}
+	}
+
+	messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "A map of your surroundings forms in your mind.")
+
+	return true
+}
+
+func nextToOpenGround(x, y int, gameMap *gamemap.Map) bool {
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if x+dx < 0 || y+dy < 0 || x+dx >= gameMap.Width || y+dy >= gameMap.Height {
+				continue
+			}
+
+			if !gameMap.Tiles[x+dx][y+dy].IsWall() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func effectDetectMonsters(entity *GameEntity, turns int, entities []*GameEntity, messageLog *ui.MessageLog) bool {
+	// Make the entity telepathic for a number of turns. While it lasts, every creature on the level can be seen,
+	// wherever it is.
+	telepathic := TelepathicComponent{Turns: turns}
+
+	if entity.HasComponent("telepathic") {
+		current, _ := entity.Components["telepathic"].(TelepathicComponent)
+		if current.Turns > telepathic.Turns {
+			telepathic = current
+		}
+		entity.RemoveComponent("telepathic")
+	}
+
+	entity.AddComponent("telepathic", telepathic)
+
+	minds := 0
+	for _, e := range entities {
+		if e != entity && IsCreature(e) {
+			minds++
+		}
+	}
+
+	if minds == 0 {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "Your mind reaches out, but finds nothing but silence.")
+	} else {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "Your mind reaches out, and brushes against "+strconv.Itoa(minds)+" others.")
+	}
+
+	return true
+}
+
+func effectDetectItems(entity *GameEntity, radius int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Sense every item lying on the floor around the entity, out to the given radius. The player remembers where each
+	// one is, but marked as sensed rather than seen, until they get a look at it for real.
+	if !entity.HasComponent("position") {
+		return false
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	found := 0
+
+	for _, e := range entities {
+		if !e.HasComponents([]string{"lootable", "position", "appearance"}) {
+			continue
+		}
+
+		itemPos, _ := e.Components["position"].(PositionComponent)
+		itemApp, _ := e.Components["appearance"].(AppearanceComponent)
+
+		if distanceTo(pos.X, pos.Y, itemPos.X, itemPos.Y) > radius {
+			continue
+		}
+
+		gameMap.Tiles[itemPos.X][itemPos.Y].Detect(itemApp.Character, itemApp.Color)
+		found++
+	}
+
+	if found == 0 {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You sense that there is nothing of value nearby.")
+	} else {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You sense the presence of "+strconv.Itoa(found)+" items nearby.")
+	}
+
+	return true
+}
+
+func SystemTelepathy(entity *GameEntity, messageLog *ui.MessageLog) {
+	// Count down how long the entity has left to be telepathic, letting the player know when it wears off
+	if !entity.HasComponent("telepathic") {
+		return
+	}
+
+	telepathic, _ := entity.Components["telepathic"].(TelepathicComponent)
+	telepathic.Turns--
+
+	entity.RemoveComponent("telepathic")
+	if telepathic.Turns > 0 {
+		entity.AddComponent("telepathic", telepathic)
+	} else if entity.HasComponent("player") {
+		messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "The voices in your head fall silent.")
+	}
+}
+
+func IsCreature(entity *GameEntity) bool {
+	// Creatures are anything alive, with a mind of its own. That includes things that never move, like fungi, but not
+	// the corpses they leave behind, which lose their hitpoints, AI and faction when they die.
+	if entity == nil || !entity.HasComponents([]string{"position", "appearance", "hitpoints"}) {
+		return false
+	}
+
+	for _, component := range []string{"basic_melee_ai", "ranged_ai", "random_movement", "faction"} {
+		if entity.HasComponent(component) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package ecs
+
+import (
+	"bearrogue/fov"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+const (
+	// How loud digging is. Not as loud as a fight, but anything close by will hear it.
+	NoiseDigging = 6
+
+	// How many walls a magical tunnel clears each turn
+	TunnelSpeed = 2
+)
+
+func SystemDig(entity *GameEntity, x, y int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Spend a turn digging at the wall at the given location, using the best digging tool the entity is carrying.
+	// Walls take several turns to dig through, and starting on a different wall means starting over. Returns true if
+	// any digging was done.
+	tool := findDigger(entity, entities)
+	if tool == nil {
+		return false
+	}
+
+	tile := gameMap.Tiles[x][y]
+	if !tile.IsDiggable() {
+		if entity.HasComponent("player") {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The "+tile.TileType().Name+" is far too hard to dig through.")
+		}
+		return false
+	}
+
+	digger, _ := tool.Components["digger"].(DiggerComponent)
+	toolApp, _ := tool.Components["appearance"].(AppearanceComponent)
+
+	digging, ok := entity.Components["digging"].(DiggingComponent)
+	if !ok || digging.X != x || digging.Y != y {
+		digging = DiggingComponent{X: x, Y: y, Tunnel: digging.Tunnel}
+
+		if entity.HasComponent("player") {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You start digging through the "+tile.TileType().Name+" with the "+toolApp.ColoredName()+".")
+		}
+	}
+
+	digging.Progress += digger.Speed
+	SystemNoise(x, y, NoiseDigging, entity, entities, gameMap, messageLog)
+
+	entity.RemoveComponent("digging")
+
+	if digging.Progress >= tile.TileType().DigTurns {
+		gameMap.ChangeTile(x, y, gamemap.TileFloor)
+
+		if entity.HasComponent("player") {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You break through!")
+		}
+
+		// Any magical tunnel still being dug carries on
+		if len(digging.Tunnel) > 0 {
+			entity.AddComponent("digging", DiggingComponent{Tunnel: digging.Tunnel})
+		}
+		return true
+	}
+
+	entity.AddComponent("digging", digging)
+	return true
+}
+
+func effectTunnel(entity *GameEntity, targetX, targetY, power int, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Start a tunnel from the entity towards the target location, power tiles long. Every wall along the way, up to the
+	// first one that cannot be dug through, is queued up on the entities digging component, and SystemTunnel then
+	// turns them into floor a few at a time, over the turns that follow.
+	pos, _ := entity.Components["position"].(PositionComponent)
+
+	if pos.X == targetX && pos.Y == targetY {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "You need to pick a direction to tunnel in.")
+		return false
+	}
+
+	// Extend the line well past the target, so the tunnel carries on for its full length
+	endX, endY := pos.X+(targetX-pos.X)*gameMap.Width, pos.Y+(targetY-pos.Y)*gameMap.Height
+	var tunnel []fov.Point
+
+	for i, point := range fov.Line(pos.X, pos.Y, endX, endY)[1:] {
+		if i >= power || point.X < 0 || point.Y < 0 || point.X >= gameMap.Width || point.Y >= gameMap.Height {
+			break
+		}
+
+		tile := gameMap.Tiles[point.X][point.Y]
+		if !tile.IsWall() {
+			continue
+		}
+
+		if !tile.IsDiggable() {
+			break
+		}
+
+		tunnel = append(tunnel, point)
+	}
+
+	if len(tunnel) == 0 {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The rock shudders, but holds firm.")
+		return true
+	}
+
+	// A new tunnel replaces any that was still being dug, but leaves any digging by hand where it was
+	digging, _ := entity.Components["digging"].(DiggingComponent)
+	digging.Tunnel = tunnel
+
+	entity.RemoveComponent("digging")
+	entity.AddComponent("digging", digging)
+
+	messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The rock begins to crumble away before you!")
+
+	return true
+}
+
+func SystemTunnel(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Carry on digging any magical tunnel the entity has started, clearing up to TunnelSpeed walls from the front of
+	// the queue. The tunnel ends early if it runs into something that cannot be dug through.
+	digging, ok := entity.Components["digging"].(DiggingComponent)
+	if !ok || len(digging.Tunnel) == 0 {
+		return
+	}
+
+	dug := 0
+	var last fov.Point
+	for dug < TunnelSpeed && len(digging.Tunnel) > 0 {
+		point := digging.Tunnel[0]
+		tile := gameMap.Tiles[point.X][point.Y]
+
+		if !tile.IsWall() {
+			// Something else already cleared this one
+			digging.Tunnel = digging.Tunnel[1:]
+			continue
+		}
+
+		if !tile.IsDiggable() {
+			digging.Tunnel = nil
+			break
+		}
+
+		gameMap.ChangeTile(point.X, point.Y, gamemap.TileFloor)
+		last = point
+
+		digging.Tunnel = digging.Tunnel[1:]
+		dug++
+	}
+
+	if dug > 0 {
+		SystemNoise(last.X, last.Y, NoiseDigging, entity, entities, gameMap, messageLog)
+	}
+
+	entity.RemoveComponent("digging")
+	entity.AddComponent("digging", digging)
+
+	if len(digging.Tunnel) == 0 && entity.HasComponent("player") {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The rumbling of the tunnel dies away.")
+	}
+}
+
+func findDigger(entity *GameEntity, entities []*GameEntity) *GameEntity {
+	// Find the fastest digging tool the entity is carrying
+	var best *GameEntity
+	bestSpeed := 0
+
+	for _, item := range ItemsOwnedByEntity(entity, entities) {
+		if digger, ok := item.Components["digger"].(DiggerComponent); ok && digger.Speed > bestSpeed {
+			best, bestSpeed = item, digger.Speed
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,167 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+const (
+	// How far (in tiles searched) monsters will look for a way around walls and doors
+	PathSearchLimit = 400
+)
+
+func CanOpenDoors(entity *GameEntity) bool {
+	// The player can always open doors. Monsters can only if they are clever (or have hands) enough.
+	if entity.HasComponent("player") {
+		return true
+	}
+
+	movement, ok := entity.Components["movement"].(MovementComponent)
+	return ok && movement.CanOpenDoors
+}
+
+func SystemOpenDoor(entity *GameEntity, x, y int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Open the door at the given location. Locked doors need a key, which is used up unlocking it. Returns true if the
+	// door was opened (which takes a turn).
+	tile := gameMap.Tiles[x][y]
+	appearance, _ := entity.Components["appearance"].(AppearanceComponent)
+	witnessed := entity.HasComponent("player") || gameMap.IsVisibleToPlayer(x, y)
+
+	if !CanOpenDoors(entity) || (tile.Type != gamemap.TileDoorClosed && tile.Type != gamemap.TileDoorLocked) {
+		return false
+	}
+
+	if tile.Type == gamemap.TileDoorLocked {
+		key := findKey(entity, entities)
+		if key == nil {
+			if entity.HasComponent("player") {
+				messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The door is locked. You will need a key.")
+			}
+			return false
+		}
+
+		keyApp, _ := key.Components["appearance"].(AppearanceComponent)
+
+		// The key is used up, and taken out of the game, like any other item that is used
+		inv, _ := entity.Components["inventory"].(InventoryComponent)
+
+		key.RemoveComponent("lootable")
+		inv.Items = ItemsOwnedByEntity(entity, entities)
+
+		entity.RemoveComponent("inventory")
+		entity.AddComponent("inventory", inv)
+
+		if witnessed {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The "+appearance.ColoredName()+" unlocks the door with the "+keyApp.ColoredName()+".")
+		}
+	} else if witnessed {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The "+appearance.ColoredName()+" opens the door.")
+	}
+
+	gameMap.ChangeTile(x, y, gamemap.TileDoorOpen)
+	return true
+}
+
+func SystemCloseDoor(entity *GameEntity, x, y int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Close the open door at the given location. A door cannot be closed with anything (or anyone) in the way. Returns
+	// true if the door was closed (which takes a turn).
+	tile := gameMap.Tiles[x][y]
+	appearance, _ := entity.Components["appearance"].(AppearanceComponent)
+
+	if tile.Type != gamemap.TileDoorOpen {
+		if entity.HasComponent("player") {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "There is no open door there.")
+		}
+		return false
+	}
+
+	if len(GetEntitiesPresentAtLocation(entities, x, y)) > 0 {
+		if entity.HasComponent("player") {
+			messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "Something is in the way of the door.")
+		}
+		return false
+	}
+
+	gameMap.ChangeTile(x, y, gamemap.TileDoorClosed)
+
+	if entity.HasComponent("player") || gameMap.IsVisibleToPlayer(x, y) {
+		messageLog.Send(ui.CategorySystem, ui.SeverityInfo, "The "+appearance.ColoredName()+" closes the door.")
+	}
+	return true
+}
+
+func AdjacentOpenDoors(x, y int, gameMap *gamemap.Map) [][]int {
+	// Return the direction (dx, dy) of every open door next to the given location
+	var doors [][]int
+
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if (dx != 0 || dy != 0) && gameMap.Tiles[x+dx][y+dy].Type == gamemap.TileDoorOpen {
+				doors = append(doors, []int{dx, dy})
+			}
+		}
+	}
+
+	return doors
+}
+
+func findKey(entity *GameEntity, entities []*GameEntity) *GameEntity {
+	for _, item := range ItemsOwnedByEntity(entity, entities) {
+		if item.HasComponent("key") {
+			return item
+		}
+	}
+	return nil
+}
+
+func pathStep(entity *GameEntity, targetX, targetY int, gameMap *gamemap.Map) (int, int, bool) {
+	// Find the direction of the first step along the best path to the target, going through doors if the entity can
+	// open them, and around anything dangerous. The path is remembered, and followed again next turn, unless the target
+	// has moved, or the map has changed somewhere along it.
+	pos, _ := entity.Components["position"].(PositionComponent)
+	path, cached := entity.Components["path"].(PathComponent)
+
+	if cached {
+		// Drop any steps already taken
+		for len(path.Steps) > 0 && path.Steps[0].X == pos.X && path.Steps[0].Y == pos.Y {
+			path.Steps = path.Steps[1:]
+		}
+
+		cached = len(path.Steps) > 0 && path.Map == gameMap && path.TargetX == targetX && path.TargetY == targetY &&
+			distanceTo(pos.X, pos.Y, path.Steps[0].X, path.Steps[0].Y) == 1 && !pathChanged(path, gameMap)
+	}
+
+	if !cached {
+		canOpen := CanOpenDoors(entity)
+
+		steps := gameMap.FindPath(pos.X, pos.Y, targetX, targetY, PathSearchLimit, func(tile *gamemap.Tile) bool {
+			if tile.Type == gamemap.TileDoorClosed {
+				return canOpen
+			}
+			return !tile.Blocked && !tile.IsHazardous()
+		})
+
+		path = PathComponent{Steps: steps, TargetX: targetX, TargetY: targetY, Map: gameMap, Revision: gameMap.Revision}
+	}
+
+	entity.RemoveComponent("path")
+
+	if len(path.Steps) == 0 {
+		return 0, 0, false
+	}
+
+	entity.AddComponent("path", path)
+	return path.Steps[0].X - pos.X, path.Steps[0].Y - pos.Y, true
+}
+
+func pathChanged(path PathComponent, gameMap *gamemap.Map) bool {
+	// Check whether any tile along the path has changed since the path was found
+	for _, changed := range gameMap.ChangedSince(path.Revision) {
+		for _, step := range path.Steps {
+			if step == changed {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"testing"
+)
+
+func TestSystemOpenDoor(t *testing.T) {
+	tests := []struct {
+		name         string
+		door         int
+		player       bool
+		canOpenDoors bool
+		hasKey       bool
+		expectOpened bool
+		expectTile   int
+	}{
+		{"player opens a closed door", gamemap.TileDoorClosed, true, false, false, true, gamemap.TileDoorOpen},
+		{"player without a key", gamemap.TileDoorLocked, true, false, false, false, gamemap.TileDoorLocked},
+		{"player with a key", gamemap.TileDoorLocked, true, false, true, true, gamemap.TileDoorOpen},
+		{"monster with hands", gamemap.TileDoorClosed, false, true, false, true, gamemap.TileDoorOpen},
+		{"monster without hands", gamemap.TileDoorClosed, false, false, false, false, gamemap.TileDoorClosed},
+		{"monster with hands, and a key", gamemap.TileDoorLocked, false, true, true, true, gamemap.TileDoorOpen},
+		{"door already open", gamemap.TileDoorOpen, true, false, false, false, gamemap.TileDoorOpen},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gameMap := openMap(10, 10)
+			gameMap.Tiles[5][5].SetType(test.door)
+
+			entity := newEntity(map[string]Component{
+				"position":   PositionComponent{X: 4, Y: 5},
+				"appearance": AppearanceComponent{Name: "Opener"},
+				"movement":   MovementComponent{CanOpenDoors: test.canOpenDoors},
+				"inventory":  InventoryComponent{Capacity: 10},
+			})
+			if test.player {
+				entity.AddComponent("player", PlayerComponent{})
+			}
+
+			entities := []*GameEntity{entity}
+
+			var key *GameEntity
+			if test.hasKey {
+				key = newEntity(map[string]Component{
+					"key":        KeyComponent{},
+					"lootable":   LootableComponent{InInventory: true, Owner: entity},
+					"appearance": AppearanceComponent{Name: "Key"},
+				})
+				entities = append(entities, key)
+			}
+
+			if opened := SystemOpenDoor(entity, 5, 5, entities, gameMap, newMessageLog()); opened != test.expectOpened {
+				t.Errorf("expected opening the door to be %v, got %v", test.expectOpened, opened)
+			}
+
+			if gameMap.Tiles[5][5].Type != test.expectTile {
+				t.Errorf("expected the door to be tile type %d, got %d", test.expectTile, gameMap.Tiles[5][5].Type)
+			}
+
+			// Unlocking a door uses up the key
+			if key != nil && test.door == gamemap.TileDoorLocked {
+				if findKey(entity, entities) != nil {
+					t.Error("expected the key to be used up")
+				}
+
+				inv, _ := entity.Components["inventory"].(InventoryComponent)
+				if len(inv.Items) != 0 {
+					t.Errorf("expected an empty inventory, got %d items", len(inv.Items))
+				}
+			}
+		})
+	}
+}
+
+func TestSystemOpenDoorUsesOneKey(t *testing.T) {
+	gameMap := openMap(10, 10)
+	gameMap.Tiles[5][5].SetType(gamemap.TileDoorLocked)
+	gameMap.Tiles[5][6].SetType(gamemap.TileDoorLocked)
+
+	player := newEntity(map[string]Component{
+		"player":     PlayerComponent{},
+		"position":   PositionComponent{X: 4, Y: 5},
+		"appearance": AppearanceComponent{Name: "Player"},
+		"inventory":  InventoryComponent{Capacity: 10},
+	})
+	entities := []*GameEntity{player}
+
+	for i := 0; i < 2; i++ {
+		entities = append(entities, newEntity(map[string]Component{
+			"key":        KeyComponent{},
+			"lootable":   LootableComponent{InInventory: true, Owner: player},
+			"appearance": AppearanceComponent{Name: "Key"},
+		}))
+	}
+
+	messageLog := newMessageLog()
+	SystemOpenDoor(player, 5, 5, entities, gameMap, messageLog)
+
+	if findKey(player, entities) == nil {
+		t.Fatal("expected one key to be left after unlocking a single door")
+	}
+
+	if !SystemOpenDoor(player, 5, 6, entities, gameMap, messageLog) {
+		t.Error("expected the second key to unlock the second door")
+	}
+
+	if findKey(player, entities) != nil {
+		t.Error("expected both keys to be used up")
+	}
+}
+
+func TestSystemCloseDoor(t *testing.T) {
+	tests := []struct {
+		name         string
+		door         int
+		blocked      bool
+		expectClosed bool
+		expectTile   int
+	}{
+		{"open door", gamemap.TileDoorOpen, false, true, gamemap.TileDoorClosed},
+		{"something in the way", gamemap.TileDoorOpen, true, false, gamemap.TileDoorOpen},
+		{"no open door", gamemap.TileDoorClosed, false, false, gamemap.TileDoorClosed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gameMap := openMap(10, 10)
+			gameMap.Tiles[5][5].SetType(test.door)
+
+			player := newEntity(map[string]Component{
+				"player":     PlayerComponent{},
+				"position":   PositionComponent{X: 4, Y: 5},
+				"appearance": AppearanceComponent{Name: "Player"},
+			})
+			entities := []*GameEntity{player}
+
+			if test.blocked {
+				entities = append(entities, newEntity(map[string]Component{
+					"position":   PositionComponent{X: 5, Y: 5},
+					"appearance": AppearanceComponent{Name: "Rock"},
+				}))
+			}
+
+			if closed := SystemCloseDoor(player, 5, 5, entities, gameMap, newMessageLog()); closed != test.expectClosed {
+				t.Errorf("expected closing the door to be %v, got %v", test.expectClosed, closed)
+			}
+
+			if gameMap.Tiles[5][5].Type != test.expectTile {
+				t.Errorf("expected the door to be tile type %d, got %d", test.expectTile, gameMap.Tiles[5][5].Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+var targetedEffects = map[string]bool{
+	// Effects that need a location to be picked before they can be used
+	"tunnel": true,
+}
+
+func ItemNeedsTarget(item *GameEntity) bool {
+	usable, ok := item.Components["usable"].(UsableComponent)
+	return ok && targetedEffects[usable.Effect]
+}
+
+func SystemUseItem(entity *GameEntity, item *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) []*GameEntity {
+	// Use an item on the entity itself (or wherever it is standing)
+	pos, _ := entity.Components["position"].(PositionComponent)
+	return SystemUseItemAt(entity, item, pos.X, pos.Y, entities, gameMap, messageLog)
+}
+
+func SystemUseItemAt(entity *GameEntity, item *GameEntity, targetX, targetY int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) []*GameEntity {
+	// Use an item from the entities inventory, aimed at the given location. What happens depends on the effect of the
+	// item. Items are used up once their effect has taken place. Any entities created by the effect (summoned allies,
+	// for example) are returned, so they can be added to the game.
+	var createdEntities []*GameEntity
+
+	if !entity.HasComponents([]string{"inventory", "appearance"}) || !item.HasComponents([]string{"lootable", "appearance"}) {
+		return createdEntities
+	}
+
+	itemApp, _ := item.Components["appearance"].(AppearanceComponent)
+
+	if !item.HasComponent("usable") {
+		messageLog.Send(ui.CategoryItem, ui.SeverityInfo, "You are not sure how to use the "+itemApp.ColoredName())
+		return createdEntities
+	}
+
+	usable, _ := item.Components["usable"].(UsableComponent)
+	used := false
+
+	switch usable.Effect {
+	case "summon_ally":
+		createdEntities, used = effectSummonAlly(entity, usable.Power, entities, gameMap, messageLog)
+	case "tunnel":
+		used = effectTunnel(entity, targetX, targetY, usable.Power, gameMap, messageLog)
+	case "magic_mapping":
+		used = effectMagicMapping(entity, usable.Power, gameMap, messageLog)
+	case "detect_monsters":
+		used = effectDetectMonsters(entity, usable.Power, entities, messageLog)
+	case "detect_items":
+		used = effectDetectItems(entity, usable.Power, entities, gameMap, messageLog)
+	}
+
+	if used {
+		// The item is used up. Removing it from the inventory, without giving it a position, takes it out of the game
+		inv, _ := entity.Components["inventory"].(InventoryComponent)
+
+		item.RemoveComponent("lootable")
+		inv.Items = ItemsOwnedByEntity(entity, entities)
+
+		entity.RemoveComponent("inventory")
+		entity.AddComponent("inventory", inv)
+	}
+
+	return createdEntities
+}
+
+func effectSummonAlly(entity *GameEntity, power int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) ([]*GameEntity, bool) {
+	// Summon a number of spirit wolves next to the entity, which will follow it around, and fight for it
+	var summoned []*GameEntity
+
+	if !entity.HasComponent("position") {
+		return summoned, false
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+
+	occupied := append([]*GameEntity{}, entities...)
+
+	for i := 0; i < power; i++ {
+		x, y, found := findOpenNeighbor(pos.X, pos.Y, occupied, gameMap)
+
+		if !found {
+			break
+		}
+
+		wolf := &GameEntity{}
+		wolf.SetupGameEntity()
+		wolf.AddComponents(map[string]Component{"position": PositionComponent{X: x, Y: y},
+			"appearance":     AppearanceComponent{Layer: ActorLayer, Character: "w", Color: "light blue", Name: "Spirit Wolf"},
+			"hitpoints":      HitPointComponent{Hp: 12, MaxHP: 12},
+			"block":          BlockingComponent{},
+			"movement":       MovementComponent{},
+			"basic_melee_ai": BasicMeleeAIComponent{},
+			"attacker":       AttackerComponent{Attack: 5, Defense: 3},
+			"perception":     PerceptionComponent{SightRadius: 8, Hearing: 3},
+			"killable":       KillableComponent{Name: "Fading wisps of", Color: "light blue", Character: "~"}})
+
+		MakeFollower(wolf, entity)
+
+		summoned = append(summoned, wolf)
+		occupied = append(occupied, wolf)
+	}
+
+	if len(summoned) == 0 {
+		messageLog.Send(ui.CategoryItem, ui.SeverityInfo, "There is no room for anything to answer your call.")
+		return summoned, false
+	}
+
+	messageLog.Send(ui.CategoryItem, ui.SeverityGood, "A "+ui.Colored("Spirit Wolf", "light blue")+" shimmers into being at your side!")
+
+	return summoned, true
+}
+
+func findOpenNeighbor(x, y int, entities []*GameEntity, gameMap *gamemap.Map) (int, int, bool) {
+	// Find a tile next to the given location that is neither a wall, nor has anything blocking standing on it
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+
+			if !gameMap.IsBlocked(x+dx, y+dy) && GetBlockingEntitiesAtLocation(entities, x+dx, y+dy) == nil {
+				return x + dx, y + dy, true
+			}
+		}
+	}
+
+	return x, y, false
+}
@@ -97,15 +97,21 @@ func (e *GameEntity) GetComponent(componentName string) Component {
 }
 
 func (e *GameEntity) HasAIComponent() string {
-	// Check to see if the entity has an AI Component
+	// Check to see if the entity has an AI Component. If it has more than one, the one whose behavior was registered
+	// with the highest priority wins, with ties broken by name, so the choice does not depend on map ordering.
+	chosen := ""
+
 	for name, component := range e.Components {
-		if component.IsAIComponent() {
-			// Just return the first AI component encountered, as each entity should not have more than one anyways
-			return name
+		if !component.IsAIComponent() {
+			continue
+		}
+
+		if chosen == "" || aiPriority(name) > aiPriority(chosen) || (aiPriority(name) == aiPriority(chosen) && name < chosen) {
+			chosen = name
 		}
 	}
 
-	return ""
+	return chosen
 }
 
 func (e *GameEntity) Print() {
@@ -0,0 +1,111 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+)
+
+const (
+	// Relationships between factions
+	Hostile = iota
+	Neutral
+	Allied
+)
+
+// How each faction feels about the others. Relationships are looked up in both directions, so each pair only needs to
+// be listed once. Entities in the same faction are always allied, and any pair not listed here is neutral.
+// Fungi are shunned by every other monster, and are left alone rather than fought.
+var factionRelationships = map[string]map[string]int{
+	"player": {
+		"goblins": Hostile,
+		"orcs":    Hostile,
+		"trolls":  Hostile,
+		"kobolds": Hostile,
+		"fungi":   Hostile,
+	},
+	"goblins": {
+		"orcs":    Allied,
+		"trolls":  Hostile,
+		"kobolds": Hostile,
+	},
+	"orcs": {
+		"trolls": Hostile,
+	},
+}
+
+func SetFactionRelationship(factionA, factionB string, relationship int) {
+	// Change how two factions feel about each other
+	if _, ok := factionRelationships[factionB][factionA]; ok {
+		delete(factionRelationships[factionB], factionA)
+	}
+
+	if _, ok := factionRelationships[factionA]; !ok {
+		factionRelationships[factionA] = map[string]int{}
+	}
+
+	factionRelationships[factionA][factionB] = relationship
+}
+
+func GetFactionRelationship(factionA, factionB string) int {
+	// Return the relationship between two factions, checking the table in both directions
+	if factionA == factionB {
+		return Allied
+	}
+
+	if relationship, ok := factionRelationships[factionA][factionB]; ok {
+		return relationship
+	}
+
+	if relationship, ok := factionRelationships[factionB][factionA]; ok {
+		return relationship
+	}
+
+	return Neutral
+}
+
+func GetRelationship(entity, other *GameEntity) int {
+	// Return how the entity feels about another entity. Entities without a faction are neutral to everyone.
+	if !entity.HasComponent("faction") || !other.HasComponent("faction") {
+		return Neutral
+	}
+
+	faction, _ := entity.Components["faction"].(FactionComponent)
+	otherFaction, _ := other.Components["faction"].(FactionComponent)
+
+	return GetFactionRelationship(faction.Name, otherFaction.Name)
+}
+
+func IsHostile(entity, other *GameEntity) bool {
+	return GetRelationship(entity, other) == Hostile
+}
+
+func findNearestHostile(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map) *GameEntity {
+	// Find the closest entity the given entity is hostile towards, and can currently see. Returns nil if there are none.
+	if !entity.HasComponent("position") {
+		return nil
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+
+	var nearest *GameEntity
+	nearestDistance := 0
+
+	for _, e := range entities {
+		if e == nil || e == entity || !e.HasComponents([]string{"position", "hitpoints"}) {
+			continue
+		}
+
+		if !IsHostile(entity, e) || !CanSee(entity, e, gameMap) {
+			continue
+		}
+
+		ePos, _ := e.Components["position"].(PositionComponent)
+		distance := distanceTo(pos.X, pos.Y, ePos.X, ePos.Y)
+
+		if nearest == nil || distance < nearestDistance {
+			nearest = e
+			nearestDistance = distance
+		}
+	}
+
+	return nearest
+}
@@ -0,0 +1,74 @@
+package ecs
+
+import "testing"
+
+func TestGetFactionRelationship(t *testing.T) {
+	tests := []struct {
+		a      string
+		b      string
+		expect int
+	}{
+		{"player", "orcs", Hostile},
+		{"orcs", "player", Hostile},
+		{"goblins", "orcs", Allied},
+		{"orcs", "goblins", Allied},
+		{"trolls", "orcs", Hostile},
+		{"kobolds", "goblins", Hostile},
+		{"orcs", "orcs", Allied},
+		{"fungi", "goblins", Neutral},
+		{"player", "strays", Neutral},
+		{"nobody", "anybody", Neutral},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" and "+test.b, func(t *testing.T) {
+			if relationship := GetFactionRelationship(test.a, test.b); relationship != test.expect {
+				t.Errorf("expected %d, got %d", test.expect, relationship)
+			}
+		})
+	}
+}
+
+func TestGetRelationship(t *testing.T) {
+	player := newEntity(map[string]Component{"faction": FactionComponent{Name: "player"}})
+	orc := newEntity(map[string]Component{"faction": FactionComponent{Name: "orcs"}})
+	rock := newEntity(map[string]Component{})
+
+	tests := []struct {
+		name   string
+		entity *GameEntity
+		other  *GameEntity
+		expect int
+	}{
+		{"player and orc", player, orc, Hostile},
+		{"orc and player", orc, player, Hostile},
+		{"player and something without a faction", player, rock, Neutral},
+		{"something without a faction and an orc", rock, orc, Neutral},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if relationship := GetRelationship(test.entity, test.other); relationship != test.expect {
+				t.Errorf("expected %d, got %d", test.expect, relationship)
+			}
+
+			if hostile := IsHostile(test.entity, test.other); hostile != (test.expect == Hostile) {
+				t.Errorf("expected IsHostile to be %v", test.expect == Hostile)
+			}
+		})
+	}
+}
+
+func TestSetFactionRelationship(t *testing.T) {
+	defer SetFactionRelationship("player", "goblins", Hostile)
+
+	// Setting the relationship from the other direction replaces the one already in the table
+	SetFactionRelationship("goblins", "player", Allied)
+
+	if relationship := GetFactionRelationship("player", "goblins"); relationship != Allied {
+		t.Errorf("expected the player and goblins to be allied, got %d", relationship)
+	}
+	if relationship := GetFactionRelationship("goblins", "player"); relationship != Allied {
+		t.Errorf("expected the goblins and player to be allied, got %d", relationship)
+	}
+}
@@ -0,0 +1,50 @@
+package ecs
+
+import (
+	"bearrogue/fov"
+	"bearrogue/gamemap"
+)
+
+func SystemLighting(entities []*GameEntity, gameMap *gamemap.Map, caster fov.Caster) {
+	// Light up the map. As well as glowing tiles (lava, fungus), light comes from entities with a LightComponent
+	// (braziers, torches), whether they are lying on the floor or being carried.
+	var sources []gamemap.LightSource
+
+	for _, entity := range entities {
+		if !entity.HasComponent("light") {
+			continue
+		}
+
+		light, _ := entity.Components["light"].(LightComponent)
+
+		if x, y, ok := lightPosition(entity); ok {
+			sources = append(sources, gamemap.LightSource{X: x, Y: y, Radius: light.Radius, Color: light.Color})
+		}
+	}
+
+	gameMap.UpdateLighting(sources, func(x, y, radius int) []*gamemap.Tile {
+		return caster.Cast(x, y, radius, gameMap)
+	})
+}
+
+func lightPosition(entity *GameEntity) (int, int, bool) {
+	// Return where the light given off by an entity comes from. Anything being carried lights up the area around
+	// whoever is carrying it.
+	if entity.HasComponent("lootable") {
+		lootable, _ := entity.Components["lootable"].(LootableComponent)
+
+		if lootable.InInventory {
+			if lootable.Owner == nil || !lootable.Owner.HasComponent("position") {
+				return 0, 0, false
+			}
+			entity = lootable.Owner
+		}
+	}
+
+	if !entity.HasComponent("position") {
+		return 0, 0, false
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	return pos.X, pos.Y, true
+}
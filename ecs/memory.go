@@ -0,0 +1,39 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+)
+
+func SystemRemember(entities []*GameEntity, gameMap *gamemap.Map) {
+	// Update what the player remembers seeing on each tile in view. Only things that stay put (items, corpses, traps
+	// that have been found, and so on) are remembered, since anything that moves around will not be where it was last
+	// seen for long. Where several things share a tile, the one drawn on top is remembered. Tiles in view that have
+	// nothing on them any more are forgotten, as is anything only sensed on them by magic, now it has been seen.
+	for _, tile := range gameMap.Visibility.VisibleTiles() {
+		tile.Forget()
+	}
+
+	layers := map[*gamemap.Tile]int{}
+
+	for _, e := range entities {
+		if e == nil || !e.HasComponents([]string{"position", "appearance"}) || e.HasComponent("movement") || IsHiddenTrap(e) {
+			continue
+		}
+
+		pos, _ := e.Components["position"].(PositionComponent)
+		app, _ := e.Components["appearance"].(AppearanceComponent)
+
+		if !gameMap.IsVisibleToPlayer(pos.X, pos.Y) {
+			continue
+		}
+
+		tile := gameMap.Tiles[pos.X][pos.Y]
+
+		if layer, ok := layers[tile]; ok && layer > app.Layer {
+			continue
+		}
+
+		layers[tile] = app.Layer
+		tile.Remember(app.Character, app.Color)
+	}
+}
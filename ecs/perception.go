@@ -0,0 +1,172 @@
+package ecs
+
+import (
+	"bearrogue/fov"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+const (
+	// Awareness states for entities with a PerceptionComponent
+	Asleep = iota
+	Unaware
+	Alert
+)
+
+const (
+	DefaultSightRadius = 8
+	NoiseRunning       = 5
+	NoiseCombat        = 8
+)
+
+func getPerception(entity *GameEntity) PerceptionComponent {
+	// Return the perception component of the entity. Entities without one are treated as awake, but unaware, with an
+	// average sight radius
+	if entity.HasComponent("perception") {
+		perception, _ := entity.Components["perception"].(PerceptionComponent)
+		return perception
+	}
+
+	return PerceptionComponent{SightRadius: DefaultSightRadius, State: Unaware}
+}
+
+func CanSee(entity, target *GameEntity, gameMap *gamemap.Map) bool {
+	// Check to see if the entity can see the target, using the entities own sight radius and line of sight. Sleeping
+	// entities cannot see anything. An entity that is not yet aware of a sneaking target will only notice it at half
+	// its normal sight radius.
+	if !entity.HasComponent("position") || !target.HasComponent("position") {
+		return false
+	}
+
+	perception := getPerception(entity)
+
+	if perception.State == Asleep {
+		return false
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	targetPos, _ := target.Components["position"].(PositionComponent)
+
+	radius := perception.SightRadius
+	if perception.State != Alert && target.HasComponent("sneaking") {
+		radius = radius / 2
+	}
+
+	return fov.HasLineOfSight(pos.X, pos.Y, targetPos.X, targetPos.Y, radius, gameMap)
+}
+
+func SystemPerception(entity *GameEntity, target *GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) PerceptionComponent {
+	// Update what the entity knows about the target. If the target can be seen, the entity becomes alert, and
+	// remembers where the target was last spotted. The updated perception is returned.
+	perception := getPerception(entity)
+
+	if target == nil || !target.HasComponent("position") {
+		return perception
+	}
+
+	if CanSee(entity, target, gameMap) {
+		targetPos, _ := target.Components["position"].(PositionComponent)
+
+		if perception.State != Alert && entity.HasComponents([]string{"position", "appearance"}) {
+			pos, _ := entity.Components["position"].(PositionComponent)
+			app, _ := entity.Components["appearance"].(AppearanceComponent)
+
+			if gameMap.IsVisibleToPlayer(pos.X, pos.Y) && target.HasComponent("player") {
+				messageLog.Send(ui.CategoryFlavor, ui.SeverityWarning, "The "+app.ColoredName()+" notices you!")
+			}
+		}
+
+		perception.State = Alert
+		perception.LastKnownX = targetPos.X
+		perception.LastKnownY = targetPos.Y
+		perception.HasLastKnown = true
+	}
+
+	if entity.HasComponent("perception") {
+		entity.RemoveComponent("perception")
+		entity.AddComponent("perception", perception)
+	}
+
+	return perception
+}
+
+func SystemNoise(x, y, volume int, source *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Make a noise at the given location. Every entity with a perception component close enough to hear it will wake
+	// up (if sleeping), become alert, and go to investigate where the noise came from. How far a noise carries is its
+	// volume, plus the hearing of the listener. Noise does not travel through solid rock, so distance is counted in
+	// steps around the walls, and a noise on the far side of a wall may not be heard at all.
+	if volume <= 0 {
+		return
+	}
+
+	var listeners []*GameEntity
+	loudest := 0
+
+	for _, e := range entities {
+		if e == nil || e == source || !e.HasComponents([]string{"perception", "position"}) {
+			continue
+		}
+
+		perception, _ := e.Components["perception"].(PerceptionComponent)
+		if volume+perception.Hearing > loudest {
+			loudest = volume + perception.Hearing
+		}
+		listeners = append(listeners, e)
+	}
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	steps := gameMap.StepDistances(x, y, loudest, func(tile *gamemap.Tile) bool {
+		return !tile.IsWall()
+	})
+
+	for _, e := range listeners {
+		perception, _ := e.Components["perception"].(PerceptionComponent)
+		pos, _ := e.Components["position"].(PositionComponent)
+
+		if distance, heard := steps[gameMap.Tiles[pos.X][pos.Y]]; !heard || distance > volume+perception.Hearing {
+			continue
+		}
+
+		if perception.State == Asleep && e.HasComponent("appearance") && gameMap.IsVisibleToPlayer(pos.X, pos.Y) {
+			app, _ := e.Components["appearance"].(AppearanceComponent)
+			messageLog.Send(ui.CategoryFlavor, ui.SeverityWarning, "The "+app.ColoredName()+" wakes up!")
+		}
+
+		perception.State = Alert
+		perception.LastKnownX = x
+		perception.LastKnownY = y
+		perception.HasLastKnown = true
+
+		e.RemoveComponent("perception")
+		e.AddComponent("perception", perception)
+	}
+}
+
+func SystemSpotting(player *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Let the player know about any hostile creatures standing in the part of the map that came into view this turn.
+	// Creatures that were already in view, or that walked into view across tiles that were, do not need pointing out.
+	shown := map[*gamemap.Tile]bool{}
+	for _, tile := range gameMap.Visibility.Shown() {
+		shown[tile] = true
+	}
+
+	if len(shown) == 0 {
+		return
+	}
+
+	for _, e := range entities {
+		if !IsCreature(e) || e == player || !IsHostile(e, player) {
+			continue
+		}
+
+		pos, _ := e.Components["position"].(PositionComponent)
+		app, _ := e.Components["appearance"].(AppearanceComponent)
+
+		if shown[gameMap.Tiles[pos.X][pos.Y]] {
+			messageLog.Send(ui.CategoryFlavor, ui.SeverityInfo, "You spot the "+app.ColoredName()+".")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"testing"
+)
+
+func TestCanSee(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    int
+		distance int
+		sneaking bool
+		wall     bool
+		expect   bool
+	}{
+		{"unaware, in range", Unaware, 6, false, false, true},
+		{"unaware, out of range", Unaware, 9, false, false, false},
+		{"asleep", Asleep, 2, false, false, false},
+		{"unaware, sneaking target too far for half the sight radius", Unaware, 6, true, false, false},
+		{"unaware, sneaking target within half the sight radius", Unaware, 3, true, false, true},
+		{"alert, sneaking target", Alert, 6, true, false, true},
+		{"wall in the way", Alert, 4, false, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gameMap := openMap(20, 20)
+			if test.wall {
+				gameMap.Tiles[3][10].SetType(gamemap.TileWall)
+			}
+
+			watcher := newEntity(map[string]Component{
+				"position":   PositionComponent{X: 1, Y: 10},
+				"perception": PerceptionComponent{SightRadius: 8, State: test.state},
+			})
+			target := newEntity(map[string]Component{"position": PositionComponent{X: 1 + test.distance, Y: 10}})
+			if test.sneaking {
+				target.AddComponent("sneaking", SneakingComponent{})
+			}
+
+			if seen := CanSee(watcher, target, gameMap); seen != test.expect {
+				t.Errorf("expected CanSee to be %v, got %v", test.expect, seen)
+			}
+		})
+	}
+}
+
+func TestSystemNoise(t *testing.T) {
+	// The noise is made at (8, 5). A wall runs down the map at x = 10, with a gap in it either right next to the noise,
+	// or far down at the bottom of the map, so the noise has a long way round to reach anyone on the other side.
+	tests := []struct {
+		name      string
+		listenerX int
+		listenerY int
+		hearing   int
+		gapY      int
+		expect    bool
+	}{
+		{"close by", 6, 5, 0, 5, true},
+		{"too far away", 1, 5, 0, 5, false},
+		{"too far away, but good hearing", 1, 5, 3, 5, true},
+		{"through a gap in the wall", 12, 5, 0, 5, true},
+		{"the long way round the wall", 12, 5, 0, 18, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gameMap := openMap(20, 20)
+			for y := 1; y < gameMap.Height-1; y++ {
+				if y != test.gapY {
+					gameMap.Tiles[10][y].SetType(gamemap.TileWall)
+				}
+			}
+
+			source := newEntity(map[string]Component{"position": PositionComponent{X: 8, Y: 5}})
+			listener := newEntity(map[string]Component{
+				"position":   PositionComponent{X: test.listenerX, Y: test.listenerY},
+				"perception": PerceptionComponent{SightRadius: 8, Hearing: test.hearing, State: Asleep},
+			})
+
+			SystemNoise(8, 5, 5, source, []*GameEntity{source, listener}, gameMap, newMessageLog())
+
+			perception, _ := listener.Components["perception"].(PerceptionComponent)
+			if heard := perception.State == Alert; heard != test.expect {
+				t.Fatalf("expected the listener to hear the noise to be %v, got %v", test.expect, heard)
+			}
+
+			if test.expect && (!perception.HasLastKnown || perception.LastKnownX != 8 || perception.LastKnownY != 5) {
+				t.Errorf("expected the listener to head for the noise, got %+v", perception)
+			}
+		})
+	}
+}
+
+func TestSystemNoiseIgnoresSource(t *testing.T) {
+	gameMap := openMap(10, 10)
+	source := newEntity(map[string]Component{
+		"position":   PositionComponent{X: 5, Y: 5},
+		"perception": PerceptionComponent{SightRadius: 8, State: Asleep},
+	})
+
+	SystemNoise(5, 5, 5, source, []*GameEntity{source}, gameMap, newMessageLog())
+
+	if perception, _ := source.Components["perception"].(PerceptionComponent); perception.State != Asleep {
+		t.Error("the entity making the noise should not be woken by it")
+	}
+}
@@ -0,0 +1,195 @@
+package ecs
+
+import (
+	blt "bearlibterminal"
+	"bearrogue/camera"
+	"bearrogue/fov"
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	ProjectileLayer = 5
+	ProjectileDelay = 30
+	ThrowRange      = 5
+)
+
+func SystemProjectile(shooter *GameEntity, targetX, targetY, maxRange int, character, color string, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera) (*GameEntity, int, int) {
+	// Send a projectile flying from the shooter towards the target location, along a straight line. The projectile
+	// stops at the first blocking entity it runs into, which is returned, or just short of the first wall it hits. The
+	// location the projectile came to rest at is returned as well.
+	if !shooter.HasComponent("position") {
+		return nil, targetX, targetY
+	}
+
+	pos, _ := shooter.Components["position"].(PositionComponent)
+
+	var hit *GameEntity
+	landX, landY := pos.X, pos.Y
+
+	path, hitSomething := LineOfFire(shooter, targetX, targetY, maxRange, entities, gameMap)
+
+	for _, point := range path {
+		landX, landY = point.X, point.Y
+		animateProjectile(landX, landY, character, color, gameMap, camera)
+	}
+
+	if hitSomething {
+		hit = GetBlockingEntitiesAtLocation(entities, landX, landY)
+	}
+
+	return hit, landX, landY
+}
+
+func LineOfFire(shooter *GameEntity, targetX, targetY, maxRange int, entities []*GameEntity, gameMap *gamemap.Map) ([]fov.Point, bool) {
+	// Return the path a shot from the shooter towards the target location would take, and whether it would hit
+	// something standing in the way (or at the target) before it came to rest
+	if !shooter.HasComponent("position") {
+		return nil, false
+	}
+
+	pos, _ := shooter.Components["position"].(PositionComponent)
+
+	return fov.LineOfFire(pos.X, pos.Y, targetX, targetY, maxRange, gameMap, blockedByOtherThan(shooter, entities))
+}
+
+func blockedByOtherThan(entity *GameEntity, entities []*GameEntity) func(x, y int) bool {
+	// Return a function reporting whether anything other than the given entity is blocking a location
+	return func(x, y int) bool {
+		blocker := GetBlockingEntitiesAtLocation(entities, x, y)
+		return blocker != nil && blocker != entity
+	}
+}
+
+func animateProjectile(x, y int, character, color string, gameMap *gamemap.Map, camera *camera.GameCamera) {
+	// Draw a single frame of a projectile in flight. Only projectiles the player can actually see are animated.
+	if !gameMap.IsVisibleToPlayer(x, y) {
+		return
+	}
+
+	cameraX, cameraY := camera.ToCameraCoordinates(x, y)
+
+	if cameraX < 0 || cameraY < 0 {
+		return
+	}
+
+	blt.Layer(ProjectileLayer)
+	blt.Color(blt.ColorFromName(color))
+	blt.Print(cameraX, cameraY, character)
+	blt.Refresh()
+	blt.Delay(ProjectileDelay)
+	blt.Print(cameraX, cameraY, " ")
+}
+
+func hasLineOfFire(shooter *GameEntity, targetX, targetY, maxRange int, entities []*GameEntity, gameMap *gamemap.Map) bool {
+	// Check that the shooter has a clear shot at the target location. Nothing may block sight between the two, and no
+	// other blocking entity may be standing in the way.
+	if !shooter.HasComponent("position") {
+		return false
+	}
+
+	pos, _ := shooter.Components["position"].(PositionComponent)
+
+	return fov.HasLineOfFire(pos.X, pos.Y, targetX, targetY, maxRange, gameMap, blockedByOtherThan(shooter, entities))
+}
+
+func ThrowingRange(item *GameEntity) int {
+	// Return how far the item can be thrown. Anything not made for throwing can still be thrown, just not as far.
+	if item.HasComponent("throwable") {
+		throwable, _ := item.Components["throwable"].(ThrowableComponent)
+		return throwable.Range
+	}
+	return ThrowRange
+}
+
+func moveAwayFrom(entity *GameEntity, x, y int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) bool {
+	// Step to whichever open neighboring tile is furthest from the given location. Returns false if there was nowhere
+	// further away to go.
+	positionComponent, _ := entity.Components["position"].(PositionComponent)
+
+	bestX, bestY := positionComponent.X, positionComponent.Y
+	bestDistance := distanceTo(positionComponent.X, positionComponent.Y, x, y)
+
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			newX, newY := positionComponent.X+dx, positionComponent.Y+dy
+
+			if gameMap.IsBlocked(newX, newY) || gameMap.Tiles[newX][newY].IsHazardous() || GetBlockingEntitiesAtLocation(entities, newX, newY) != nil {
+				continue
+			}
+
+			distance := distanceTo(newX, newY, x, y)
+			if distance > bestDistance {
+				bestX, bestY, bestDistance = newX, newY, distance
+			}
+		}
+	}
+
+	if bestX == positionComponent.X && bestY == positionComponent.Y {
+		return false
+	}
+
+	positionComponent.X, positionComponent.Y = bestX, bestY
+
+	entity.RemoveComponent("position")
+	entity.AddComponent("position", positionComponent)
+
+	SystemEnterTile(entity, entities, gameMap, messageLog)
+
+	return true
+}
+
+func SystemThrowItem(entity *GameEntity, item *GameEntity, targetX, targetY int, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) {
+	// Throw an item from the entities inventory at the target location. The item flies along the same path as any other
+	// projectile, damages the first thing it hits (if it is made for throwing), and then falls to the floor.
+	if entity.HasComponents([]string{"position", "inventory", "appearance"}) && item.HasComponents([]string{"lootable", "appearance"}) {
+		entityInv, _ := entity.Components["inventory"].(InventoryComponent)
+		entityApp, _ := entity.Components["appearance"].(AppearanceComponent)
+		lootable, _ := item.Components["lootable"].(LootableComponent)
+		itemApp, _ := item.Components["appearance"].(AppearanceComponent)
+
+		if lootable.Owner != entity || !lootable.InInventory {
+			return
+		}
+
+		throwable := ThrowableComponent{Damage: 1, Range: ThrowRange}
+		if item.HasComponent("throwable") {
+			throwable, _ = item.Components["throwable"].(ThrowableComponent)
+		}
+
+		messageLog.Send(ui.CategoryItem, ui.SeverityInfo, entityApp.Name+" throws the "+itemApp.ColoredName())
+
+		hit, landX, landY := SystemProjectile(entity, targetX, targetY, throwable.Range, itemApp.Character, itemApp.Color, entities, gameMap, camera)
+
+		if hit != nil && hit.HasComponents([]string{"hitpoints", "appearance"}) {
+			hitApp, _ := hit.Components["appearance"].(AppearanceComponent)
+			hitAttacker, _ := hit.Components["attacker"].(AttackerComponent)
+
+			SystemNoise(landX, landY, NoiseCombat, entity, entities, gameMap, messageLog)
+
+			// Thrown items use the same formula as melee attacks, using the items damage in place of an attack value
+			excess := throwable.Damage + rand.Intn(6) - hitAttacker.Defense
+
+			if excess > 0 {
+				messageLog.Send(ui.CategoryCombat, combatSeverity(hit), "The "+itemApp.ColoredName()+" hits the "+hitApp.ColoredName()+" for "+strconv.Itoa(excess)+" points of damage.")
+				applyDamage(entity, hit, excess, gameMap, messageLog)
+			} else {
+				messageLog.Send(ui.CategoryCombat, ui.SeverityInfo, "The "+itemApp.ColoredName()+" bounces harmlessly off the "+hitApp.ColoredName())
+			}
+		}
+
+		// The item comes to rest wherever it stopped
+		lootable.Owner = nil
+		lootable.InInventory = false
+
+		item.RemoveComponent("lootable")
+		item.AddComponents(map[string]Component{"lootable": lootable, "position": PositionComponent{X: landX, Y: landY}})
+
+		entityInv.Items = ItemsOwnedByEntity(entity, entities)
+
+		entity.RemoveComponent("inventory")
+		entity.AddComponent("inventory", entityInv)
+	}
+}
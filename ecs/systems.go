@@ -10,14 +10,21 @@ import (
 )
 
 const (
-	CorpseLayer = 2
+	// The layer creatures are drawn on. Corpses stay on the same layer once their owner dies.
+	ActorLayer     = 2
+	CorpseLayer    = ActorLayer
+	TelepathyColor = "light violet"
 )
 
 func SystemRender(entities []*GameEntity, camera *camera.GameCamera, gameMap *gamemap.Map) {
-	// Render all renderable entities to the screen
+	// Render all renderable entities to the screen. While the player is telepathic, creatures out of sight are shown
+	// as well, in a color of their own, so it is clear they are being sensed rather than seen.
+	player := getPlayerEntity(entities)
+	telepathic := player != nil && player.HasComponent("telepathic")
+
 	for _, e := range entities {
 		if e != nil {
-			if e.HasComponents([]string{"position", "appearance"}) {
+			if e.HasComponents([]string{"position", "appearance"}) && !IsHiddenTrap(e) {
 				pos, _ := e.Components["position"].(PositionComponent)
 				app, _ := e.Components["appearance"].(AppearanceComponent)
 
@@ -25,10 +32,22 @@ func SystemRender(entities []*GameEntity, camera *camera.GameCamera, gameMap *ga
 
 				cameraX, cameraY := camera.ToCameraCoordinates(pos.X, pos.Y)
 
-				if gameMap.Tiles[pos.X][pos.Y].Visible {
+				if gameMap.IsVisibleToPlayer(pos.X, pos.Y) {
+					if e.HasComponent("follower") {
+						// Mark allies with a highlighted background, so they stand out from everything else
+						blt.Layer(0)
+						blt.BkColor(blt.ColorFromName("darkest blue"))
+						blt.Print(cameraX, cameraY, " ")
+						blt.BkColor(blt.ColorFromName("black"))
+					}
+
 					blt.Layer(app.Layer)
 					blt.Color(blt.ColorFromName(app.Color))
 					blt.Print(cameraX, cameraY, app.Character)
+				} else if telepathic && e != player && IsCreature(e) && cameraX >= 0 && cameraY >= 0 {
+					blt.Layer(app.Layer)
+					blt.Color(blt.ColorFromName(TelepathyColor))
+					blt.Print(cameraX, cameraY, app.Character)
 				}
 			}
 		}
@@ -65,34 +84,55 @@ func SystemClearAt(entity *GameEntity, camera *camera.GameCamera, x, y int) {
 	}
 }
 
-func SystemMovement(entity *GameEntity, dx, dy int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+func SystemMovement(entity *GameEntity, dx, dy int, entities []*GameEntity, gameMap *gamemap.Map, camera *camera.GameCamera, messageLog *ui.MessageLog) {
 	// Allow a moveable and controllable entity to move
 	if entity.HasComponents([]string{"movement", "controllable", "position"}) {
 		// If the current entity is controllable, moveable, and has a position, go ahead and move it
 		positionComponent, _ := entity.Components["position"].(PositionComponent)
 
-		if !gameMap.IsBlocked(positionComponent.X+dx, positionComponent.Y+dy) {
+		if gameMap.Tiles[positionComponent.X+dx][positionComponent.Y+dy].IsDoor() && gameMap.IsBlocked(positionComponent.X+dx, positionComponent.Y+dy) {
+			// Bumping into a closed door opens it
+			SystemOpenDoor(entity, positionComponent.X+dx, positionComponent.Y+dy, entities, gameMap, messageLog)
+		} else if gameMap.Tiles[positionComponent.X+dx][positionComponent.Y+dy].IsWall() {
+			// Bumping into a wall digs at it, if the entity has something to dig with
+			SystemDig(entity, positionComponent.X+dx, positionComponent.Y+dy, entities, gameMap, messageLog)
+		} else if !gameMap.IsBlocked(positionComponent.X+dx, positionComponent.Y+dy) {
 			target := GetBlockingEntitiesAtLocation(entities, positionComponent.X+dx, positionComponent.Y+dy)
-			if target != nil {
-				SystemAttack(entity, target, messageLog)
+			if target != nil && target != entity && target.HasComponent("recruitable") {
+				SystemRecruit(entity, target, messageLog)
+			} else if target != nil && target != entity && target.HasComponent("follower") && GetRelationship(entity, target) == Allied {
+				// Allies get out of the way by trading places
+				swapPlaces(entity, target)
+				SystemEnterTile(entity, entities, gameMap, messageLog)
+				SystemEnterTile(target, entities, gameMap, messageLog)
+			} else if target != nil {
+				SystemAttack(entity, target, entities, gameMap, messageLog)
 			} else {
 				positionComponent.X += dx
 				positionComponent.Y += dy
 
 				entity.RemoveComponent("position")
 				entity.AddComponent("position", positionComponent)
+
+				SystemEnterTile(entity, entities, gameMap, messageLog)
+
+				// Walking is quiet, but running makes enough noise for anything nearby to hear
+				if entity.HasComponent("running") {
+					SystemNoise(positionComponent.X, positionComponent.Y, NoiseRunning, entity, entities, gameMap, messageLog)
+				}
 			}
 		}
 	} else {
-		// Check if the entity has an AI component. If it does, use that for movement
+		// An entity wading through difficult terrain loses its turn
+		if SystemSlowed(entity) {
+			return
+		}
+
+		// Check if the entity has an AI component. If it does, hand control to the behavior registered under that
+		// name (see ai.go)
 		aiComponent := entity.HasAIComponent()
-		if aiComponent != "" {
-			switch aiComponent {
-			case "random_movement":
-				SystemRandomMovement(entity, entities, gameMap, messageLog)
-			case "basic_melee_ai":
-				SystemBasicMeleeAI(entity, entities, gameMap, messageLog)
-			}
+		if behavior := GetAIBehavior(aiComponent); behavior != nil {
+			behavior.Act(entity, entities, gameMap, camera, messageLog)
 		}
 	}
 }
@@ -106,84 +146,90 @@ func SystemRandomMovement(entity *GameEntity, entities []*GameEntity, gameMap *g
 		dx := rand.Intn(3) + -1
 		dy := rand.Intn(3) + -1
 
-		if !gameMap.IsBlocked(positionComponent.X+dx, positionComponent.Y+dy) {
-			target := GetBlockingEntitiesAtLocation(entities, positionComponent.X+dx, positionComponent.Y+dy)
+		x, y := positionComponent.X+dx, positionComponent.Y+dy
+
+		if !gameMap.IsBlocked(x, y) && !gameMap.Tiles[x][y].IsHazardous() {
+			target := GetBlockingEntitiesAtLocation(entities, x, y)
 			if target != nil {
-				SystemAttack(entity, target, messageLog)
+				SystemAttack(entity, target, entities, gameMap, messageLog)
 			} else {
 				positionComponent.X += dx
 				positionComponent.Y += dy
 
 				entity.RemoveComponent("position")
 				entity.AddComponent("position", positionComponent)
+
+				SystemEnterTile(entity, entities, gameMap, messageLog)
 			}
 		}
 	}
 }
 
-func SystemBasicMeleeAI(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
-	// This is the most basic AI available. The entity will choose a target, and move towards that target until it is
-	// right next to it, then it will repeatedly attack the target. It chooses the closest viable target for its attacks
-	if entity.HasComponents([]string{"position", "movement", "appearance", "basic_melee_ai"}) {
-		//First, check to ensure the entity is within the players line of sight
-		positionComponent, _ := entity.Components["position"].(PositionComponent)
-		appearanceComponent, _ := entity.Components["appearance"].(AppearanceComponent)
-
-		if gameMap.IsVisibleToPlayer(positionComponent.X, positionComponent.Y) {
-			// The entity is currently within the players field of vision, it should do something
-			// First, pick a target (this will usually be the player, but maybe not always)
-			basicMeleeAi, _ := entity.Components["basic_melee_ai"].(BasicMeleeAIComponent)
+func moveTowards(entity *GameEntity, targetX, targetY int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Take a single step from the entities current position towards the target location. If something is blocking the
+	// way, the entity will attack (or bump into) it instead.
+	positionComponent, _ := entity.Components["position"].(PositionComponent)
 
-			// For now, use the player
-			target := getPlayerEntity(entities)
+	distance := distanceTo(positionComponent.X, positionComponent.Y, targetX, targetY)
 
-			targetPositionComponent, _ := target.Components["position"].(PositionComponent)
+	if distance == 0 {
+		return
+	}
 
-			oldTarget := basicMeleeAi.target
+	// Follow the best path to the target, if there is one. Otherwise, head straight for it.
+	dx, dy, found := pathStep(entity, targetX, targetY, gameMap)
+	if !found {
+		dx = int(Round((float64(targetX) - float64(positionComponent.X)) / float64(distance)))
+		dy = int(Round((float64(targetY) - float64(positionComponent.Y)) / float64(distance)))
+	}
 
-			// Set the target
-			basicMeleeAi.target = target
+	// Try that step first. If that is blocked by a wall, by something dangerous to walk into, or by an entity this one
+	// has no quarrel with, try to step around the obstacle at an angle instead. Closed doors are opened, by those able.
+	for _, step := range [][]int{{dx, dy}, rotateDirection(dx, dy, 1), rotateDirection(dx, dy, -1)} {
+		x, y := positionComponent.X+step[0], positionComponent.Y+step[1]
 
-			if oldTarget != basicMeleeAi.target {
-				targetAppearanceComponent, _ := basicMeleeAi.target.Components["appearance"].(AppearanceComponent)
-				messageLog.SendMessage("The [color=" + appearanceComponent.Color + "]" + appearanceComponent.Name + "[/color] throws an angry glare at [color=" + targetAppearanceComponent.Color + "]" + targetAppearanceComponent.Name + "[/color]!")
-			}
-
-			entity.RemoveComponent("basic_melee_ai")
-			entity.AddComponent("basic_melee_ai", basicMeleeAi)
+		if gameMap.Tiles[x][y].Type == gamemap.TileDoorClosed && SystemOpenDoor(entity, x, y, entities, gameMap, messageLog) {
+			return
+		}
 
-			// Now that the entity has a target, move towards it
-			distance := distanceTo(positionComponent.X, positionComponent.Y, targetPositionComponent.X, targetPositionComponent.Y)
+		if gameMap.IsBlocked(x, y) || gameMap.Tiles[x][y].IsHazardous() {
+			continue
+		}
 
-			dx := int(Round((float64(targetPositionComponent.X) - float64(positionComponent.X)) / float64(distance)))
-			dy := int(Round((float64(targetPositionComponent.Y) - float64(positionComponent.Y)) / float64(distance)))
+		target := GetBlockingEntitiesAtLocation(entities, x, y)
+		if target != nil {
+			if IsHostile(entity, target) {
+				SystemAttack(entity, target, entities, gameMap, messageLog)
+				return
+			}
+			continue
+		}
 
-			if !gameMap.IsBlocked(positionComponent.X+dx, positionComponent.Y+dy) {
-				target := GetBlockingEntitiesAtLocation(entities, positionComponent.X+dx, positionComponent.Y+dy)
-				if target != nil {
-					SystemAttack(entity, target, messageLog)
-				} else {
-					positionComponent.X += dx
-					positionComponent.Y += dy
+		positionComponent.X = x
+		positionComponent.Y = y
 
-					entity.RemoveComponent("position")
-					entity.AddComponent("position", positionComponent)
-				}
-			}
+		entity.RemoveComponent("position")
+		entity.AddComponent("position", positionComponent)
 
-		} else {
-			// The entity is not currently visible to the player, so it should just shuffle around randomly for now
-			SystemRandomMovement(entity, entities, gameMap, messageLog)
-		}
+		SystemEnterTile(entity, entities, gameMap, messageLog)
+		return
 	}
-
 }
 
-func SystemAttack(entity *GameEntity, targetEntity *GameEntity, messageLog *ui.MessageLog) {
-	// Initiate an attack against another entity
+func SystemAttack(entity *GameEntity, targetEntity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Initiate an attack against another entity. Monsters will only attack entities they are hostile towards, while the
+	// player will attack anything that is not an ally.
 	if entity.HasComponent("attacker") && entity != targetEntity {
+		relationship := GetRelationship(entity, targetEntity)
+		willAttack := relationship == Hostile || (entity.HasComponent("player") && relationship != Allied)
+
 		// Check to ensure the target entity has hitpoints. If it doesn't, check to see if it can be interacted with
-		if targetEntity.HasComponents([]string{"hitpoints", "appearance"}) {
+		if willAttack && targetEntity.HasComponents([]string{"hitpoints", "appearance"}) {
+			// Fighting is loud, and anything close enough to hear it will come and investigate
+			if targetEntity.HasComponent("position") {
+				tPositionComponent, _ := targetEntity.Components["position"].(PositionComponent)
+				SystemNoise(tPositionComponent.X, tPositionComponent.Y, NoiseCombat, entity, entities, gameMap, messageLog)
+			}
 
 			eAppearanceComponent, _ := entity.Components["appearance"].(AppearanceComponent)
 			tAppearanceComponent, _ := targetEntity.Components["appearance"].(AppearanceComponent)
@@ -199,41 +245,14 @@ func SystemAttack(entity *GameEntity, targetEntity *GameEntity, messageLog *ui.M
 				// The attack exceeded the defense of the target, so any excess should be applied as damage
 				excess := totalAttack - tAttackerComponent.Defense
 
-				tHitPointsComponent, _ := targetEntity.Components["hitpoints"].(HitPointComponent)
-
-				tHitPointsComponent.Hp -= excess
-
-				targetEntity.RemoveComponent("hitpoints")
-				targetEntity.AddComponent("hitpoints", tHitPointsComponent)
-
-				if entity.HasComponent("player") || targetEntity.HasComponent("player") {
-					messageLog.SendMessage("[color=" + eAppearanceComponent.Color + "]" + eAppearanceComponent.Name + "[/color] attacks the [color=" + tAppearanceComponent.Color + "]" + tAppearanceComponent.Name + "[/color] for " + strconv.Itoa(excess) + " points of damage.")
+				if playerWitnesses(entity, targetEntity, gameMap) {
+					messageLog.Send(ui.CategoryCombat, combatSeverity(targetEntity), eAppearanceComponent.ColoredName()+" attacks the "+tAppearanceComponent.ColoredName()+" for "+strconv.Itoa(excess)+" points of damage.")
 				}
 
-				// Check to see if this attack has reduced the targets HP to 0 or less
-				if tHitPointsComponent.Hp <= 0 {
-					// This entity has died, replace it with a corpse, and remove all movement and blocking components
-					if targetEntity.HasComponent("killable") {
-						if entity.HasComponent("player") || targetEntity.HasComponent("player") {
-							messageLog.SendMessage("The [color=" + tAppearanceComponent.Color + "]" + tAppearanceComponent.Name + "[/color] has been killed!")
-						}
-
-						killableComponent, _ := targetEntity.Components["killable"].(KillableComponent)
-
-						tAppearanceComponent.Name = killableComponent.Name + " " + tAppearanceComponent.Name
-						tAppearanceComponent.Character = killableComponent.Character
-						tAppearanceComponent.Color = killableComponent.Color
-						tAppearanceComponent.Layer = CorpseLayer
-
-						targetEntity.RemoveComponent("appearance")
-						targetEntity.AddComponent("appearance", tAppearanceComponent)
-
-						targetEntity.RemoveComponents([]string{"movement", "attacker", "block", "random_movement", "hitpoints", "reproducer"})
-					}
-				}
+				applyDamage(entity, targetEntity, excess, gameMap, messageLog)
 			} else {
-				if entity.HasComponent("player") || targetEntity.HasComponent("player") {
-					messageLog.SendMessage("[color=" + eAppearanceComponent.Color + "]" + eAppearanceComponent.Name + "[/color] attacks the [color=" + tAppearanceComponent.Color + "]" + tAppearanceComponent.Name + "[/color], but does no damage!")
+				if playerWitnesses(entity, targetEntity, gameMap) {
+					messageLog.Send(ui.CategoryCombat, ui.SeverityInfo, eAppearanceComponent.ColoredName()+" attacks the "+tAppearanceComponent.ColoredName()+", but does no damage!")
 				}
 			}
 		} else if targetEntity.HasComponent("appearance") {
@@ -242,8 +261,76 @@ func SystemAttack(entity *GameEntity, targetEntity *GameEntity, messageLog *ui.M
 			tAppearanceComponent, _ := targetEntity.Components["appearance"].(AppearanceComponent)
 
 			if entity.HasComponent("player") || targetEntity.HasComponent("player") {
-				messageLog.SendMessage("[color=" + eAppearanceComponent.Color + "]" + eAppearanceComponent.Name + "[/color] bumps into the [color=" + tAppearanceComponent.Color + "]" + tAppearanceComponent.Name + "[/color]\n")
+				messageLog.Send(ui.CategoryCombat, ui.SeverityInfo, eAppearanceComponent.ColoredName()+" bumps into the "+tAppearanceComponent.ColoredName())
+			}
+		}
+	}
+}
+
+func playerWitnesses(entity *GameEntity, targetEntity *GameEntity, gameMap *gamemap.Map) bool {
+	// Fights involving the player are always reported. Fights between other entities are only reported if the player
+	// can see them happen.
+	if entity.HasComponent("player") || targetEntity.HasComponent("player") {
+		return true
+	}
+
+	if targetEntity.HasComponent("position") {
+		pos, _ := targetEntity.Components["position"].(PositionComponent)
+		return gameMap.IsVisibleToPlayer(pos.X, pos.Y)
+	}
+
+	return false
+}
+
+func combatSeverity(targetEntity *GameEntity) int {
+	// Damage dealt to the player is worth drawing attention to. Damage dealt to anything else is just information.
+	if targetEntity.HasComponent("player") {
+		return ui.SeverityDanger
+	}
+	return ui.SeverityInfo
+}
+
+func killSeverity(targetEntity *GameEntity) int {
+	// A kill is good news, unless it was the player, or one of the player's allies, that died
+	if targetEntity.HasComponent("player") || targetEntity.HasComponent("follower") {
+		return ui.SeverityDanger
+	}
+	return ui.SeverityGood
+}
+
+func applyDamage(entity *GameEntity, targetEntity *GameEntity, damage int, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Apply damage dealt by the entity to the target. If this reduces the targets HP to 0 or less, it dies.
+	if !targetEntity.HasComponents([]string{"hitpoints", "appearance"}) {
+		return
+	}
+
+	tAppearanceComponent, _ := targetEntity.Components["appearance"].(AppearanceComponent)
+	tHitPointsComponent, _ := targetEntity.Components["hitpoints"].(HitPointComponent)
+
+	tHitPointsComponent.Hp -= damage
+
+	targetEntity.RemoveComponent("hitpoints")
+	targetEntity.AddComponent("hitpoints", tHitPointsComponent)
+
+	// Check to see if this attack has reduced the targets HP to 0 or less
+	if tHitPointsComponent.Hp <= 0 {
+		// This entity has died, replace it with a corpse, and remove all movement and blocking components
+		if targetEntity.HasComponent("killable") {
+			if playerWitnesses(entity, targetEntity, gameMap) {
+				messageLog.Send(ui.CategoryCombat, killSeverity(targetEntity), "The "+tAppearanceComponent.ColoredName()+" has been killed!")
 			}
+
+			killableComponent, _ := targetEntity.Components["killable"].(KillableComponent)
+
+			tAppearanceComponent.Name = killableComponent.Name + " " + tAppearanceComponent.Name
+			tAppearanceComponent.Character = killableComponent.Character
+			tAppearanceComponent.Color = killableComponent.Color
+			tAppearanceComponent.Layer = CorpseLayer
+
+			targetEntity.RemoveComponent("appearance")
+			targetEntity.AddComponent("appearance", tAppearanceComponent)
+
+			targetEntity.RemoveComponents([]string{"movement", "attacker", "block", "random_movement", "basic_melee_ai", "ranged_ai", "hitpoints", "reproducer", "faction", "follower", "perception"})
 		}
 	}
 }
@@ -314,8 +401,15 @@ func SystemPickupItem(entity *GameEntity, entities []*GameEntity, camera *camera
 		entitiesPresent := GetEntitiesPresentAtLocation(entities, pos.X, pos.Y)
 
 		if len(entitiesPresent) > 0 {
-			// For now, this assumes one entity per tile, which will obviously need to change
+			// For now, this assumes one item per tile, which will obviously need to change. Anything else that might
+			// be here (a trap, for example) is passed over in favor of the item.
 			targetEntity := entitiesPresent[0]
+			for _, e := range entitiesPresent {
+				if e.HasComponent("lootable") {
+					targetEntity = e
+					break
+				}
+			}
 
 			if targetEntity.HasComponents([]string{"appearance", "position"}) {
 				targetPosition, _ := targetEntity.Components["position"].(PositionComponent)
@@ -357,19 +451,19 @@ func SystemPickupItem(entity *GameEntity, entities []*GameEntity, camera *camera
 
 						SystemClearAt(targetEntity, camera, targetPosition.X, targetPosition.Y)
 
-						messageLog.SendMessage(app.Name + " picks up the [color=" + targetAppearance.Color + "]" + targetAppearance.Name + "[/color]")
+						messageLog.Send(ui.CategoryItem, ui.SeverityInfo, app.Name+" picks up the "+targetAppearance.ColoredName())
 					} else {
 						if entity.HasComponent("player") {
-							messageLog.SendMessage("Your inventory is full, and you cannot pick up the ")
+							messageLog.Send(ui.CategoryItem, ui.SeverityInfo, "Your inventory is full, and you cannot pick up the ")
 						}
 					}
 				} else {
 					// The entity present is not lootable, notify, and do not add it to inventory
-					messageLog.SendMessage("Cannot pick up that [color=" + targetAppearance.Color + "]" + targetAppearance.Name + "[/color]")
+					messageLog.Send(ui.CategoryItem, ui.SeverityInfo, "Cannot pick up that "+targetAppearance.ColoredName())
 				}
 			}
 		} else {
-			messageLog.SendMessage("There is nothing to pick up here!")
+			messageLog.Send(ui.CategoryItem, ui.SeverityInfo, "There is nothing to pick up here!")
 		}
 
 	}
@@ -402,7 +496,7 @@ func SystemDropItem(entity *GameEntity, item *GameEntity, entities []*GameEntity
 				entity.RemoveComponent("inventory")
 				entity.AddComponent("inventory", entityInv)
 
-				messageLog.SendMessage(entityApp.Name + " drops the [color=" + itemApp.Color + "]" + itemApp.Name + "[/color]")
+				messageLog.Send(ui.CategoryItem, ui.SeverityInfo, entityApp.Name+" drops the "+itemApp.ColoredName())
 			}
 		}
 	}
@@ -0,0 +1,97 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	// How much damage lava does to anything that steps into it
+	LavaDamage = 10
+)
+
+func SystemEnterTile(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Apply the effects of whatever kind of tile the entity has just stepped onto, and set off any trap waiting there
+	if !entity.HasComponents([]string{"position", "appearance"}) {
+		return
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	appearance, _ := entity.Components["appearance"].(AppearanceComponent)
+	tile := gameMap.Tiles[pos.X][pos.Y]
+	tileType := tile.TileType()
+
+	// Anything hard to cross costs extra turns
+	if tileType.MoveCost > 1 {
+		entity.RemoveComponent("slowed")
+		entity.AddComponent("slowed", SlowedComponent{Turns: tileType.MoveCost - 1})
+	}
+
+	witnessed := entity.HasComponent("player") || gameMap.IsVisibleToPlayer(pos.X, pos.Y)
+
+	switch tileType.OnEnter {
+	case gamemap.EnterBurn:
+		if witnessed {
+			messageLog.Send(ui.CategoryCombat, ui.SeverityDanger, "The "+appearance.ColoredName()+" is burned by the "+ui.Colored(tileType.Name, tileType.LitColor)+" for "+strconv.Itoa(LavaDamage)+" points of damage!")
+		}
+		applyDamage(entity, entity, LavaDamage, gameMap, messageLog)
+	case gamemap.EnterSwim:
+		// Swimming takes both hands, so something carried may slip away, and be left behind in the water
+		carried := ItemsOwnedByEntity(entity, entities)
+		if len(carried) > 0 {
+			item := carried[rand.Intn(len(carried))]
+			itemApp, _ := item.Components["appearance"].(AppearanceComponent)
+
+			dropItem(entity, item, entities)
+
+			if witnessed {
+				messageLog.Send(ui.CategoryItem, ui.SeverityWarning, "The "+itemApp.ColoredName()+" slips from the grasp of the "+appearance.ColoredName()+"!")
+			}
+		}
+	case gamemap.EnterTrample:
+		// Tall grass is flattened by anything walking through it, and no longer hides what is behind it
+		gameMap.ChangeTile(pos.X, pos.Y, gamemap.TileGrass)
+	}
+
+	SystemTriggerTraps(entity, entities, gameMap, messageLog)
+}
+
+func SystemSlowed(entity *GameEntity) bool {
+	// Check if the entity is still struggling through difficult terrain. If it is, it loses this turn, and true is
+	// returned.
+	if !entity.HasComponent("slowed") {
+		return false
+	}
+
+	slowed, _ := entity.Components["slowed"].(SlowedComponent)
+	slowed.Turns--
+
+	entity.RemoveComponent("slowed")
+	if slowed.Turns > 0 {
+		entity.AddComponent("slowed", slowed)
+	}
+
+	return true
+}
+
+func dropItem(entity *GameEntity, item *GameEntity, entities []*GameEntity) {
+	// Drop an item from the entities inventory, onto the tile the entity is standing on
+	entityPos, _ := entity.Components["position"].(PositionComponent)
+	lootable, _ := item.Components["lootable"].(LootableComponent)
+
+	lootable.Owner = nil
+	lootable.InInventory = false
+
+	item.RemoveComponent("lootable")
+	item.AddComponents(map[string]Component{"lootable": lootable, "position": PositionComponent{X: entityPos.X, Y: entityPos.Y}})
+
+	if entity.HasComponent("inventory") {
+		entityInv, _ := entity.Components["inventory"].(InventoryComponent)
+		entityInv.Items = ItemsOwnedByEntity(entity, entities)
+
+		entity.RemoveComponent("inventory")
+		entity.AddComponent("inventory", entityInv)
+	}
+}
@@ -0,0 +1,169 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	// How loud an alarm trap is. Loud enough to bring most of the level running.
+	NoiseAlarm = 25
+
+	// How close, and how likely (out of 100, before the trap's difficulty is taken off), a trap is to be found by
+	// actively searching for it, or by simply noticing it while walking past
+	SearchRadius        = 2
+	SearchChance        = 80
+	PassiveSearchRadius = 1
+	PassiveSearchChance = 25
+)
+
+func IsHiddenTrap(entity *GameEntity) bool {
+	if !entity.HasComponent("trap") {
+		return false
+	}
+
+	trap, _ := entity.Components["trap"].(TrapComponent)
+	return trap.Hidden
+}
+
+func SystemTriggerTraps(entity *GameEntity, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) {
+	// Set off any trap the entity has just stepped on. Monsters that live down here know where the traps are, and step
+	// around them, so only the player, and their allies, are caught out.
+	if !entity.HasComponents([]string{"position", "appearance"}) || (!entity.HasComponent("player") && !entity.HasComponent("follower")) {
+		return
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	appearance, _ := entity.Components["appearance"].(AppearanceComponent)
+	witnessed := entity.HasComponent("player") || gameMap.IsVisibleToPlayer(pos.X, pos.Y)
+
+	for _, e := range entities {
+		if e == nil || !e.HasComponents([]string{"trap", "position", "appearance"}) {
+			continue
+		}
+
+		// Hidden traps are not "present" as far as anything else is concerned, so check their position directly
+		trapPos, _ := e.Components["position"].(PositionComponent)
+		if trapPos.X != pos.X || trapPos.Y != pos.Y {
+			continue
+		}
+
+		trap, _ := e.Components["trap"].(TrapComponent)
+		trapApp, _ := e.Components["appearance"].(AppearanceComponent)
+
+		// Once it has gone off, there is no hiding the trap any more
+		revealTrap(e)
+
+		if witnessed {
+			messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "The "+appearance.ColoredName()+" sets off a "+trapApp.ColoredName()+"!")
+		}
+
+		switch trap.Kind {
+		case "pit":
+			// Falling in hurts, and climbing back out takes a couple of turns
+			damage := 2 + rand.Intn(6)
+			if witnessed {
+				messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "The "+appearance.ColoredName()+" falls into the pit, taking "+strconv.Itoa(damage)+" points of damage.")
+			}
+			entity.RemoveComponent("slowed")
+			entity.AddComponent("slowed", SlowedComponent{Turns: 2})
+			applyDamage(entity, entity, damage, gameMap, messageLog)
+		case "dart":
+			damage := 3 + rand.Intn(4)
+			if witnessed {
+				messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "A dart shoots out of the wall, striking the "+appearance.ColoredName()+" for "+strconv.Itoa(damage)+" points of damage.")
+			}
+			applyDamage(entity, entity, damage, gameMap, messageLog)
+		case "alarm":
+			if witnessed {
+				messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "A piercing shriek echoes through the caverns!")
+			}
+			SystemNoise(pos.X, pos.Y, NoiseAlarm, e, entities, gameMap, messageLog)
+		case "teleport":
+			if x, y, ok := randomOpenTile(pos.X, pos.Y, entities, gameMap); ok {
+				if witnessed {
+					messageLog.Send(ui.CategoryCombat, ui.SeverityWarning, "The "+appearance.ColoredName()+" vanishes in a flash of light!")
+				}
+				entity.RemoveComponent("position")
+				entity.AddComponent("position", PositionComponent{X: x, Y: y})
+			}
+		}
+	}
+}
+
+func SystemSearch(entity *GameEntity, radius, chance int, entities []*GameEntity, gameMap *gamemap.Map, messageLog *ui.MessageLog) int {
+	// Look for hidden traps within the given radius of the entity. Each one has the given chance (less its difficulty)
+	// of being found. Returns how many were found.
+	if !entity.HasComponent("position") {
+		return 0
+	}
+
+	pos, _ := entity.Components["position"].(PositionComponent)
+	found := 0
+
+	for _, e := range entities {
+		if e == nil || !IsHiddenTrap(e) || !e.HasComponents([]string{"position", "appearance"}) {
+			continue
+		}
+
+		trapPos, _ := e.Components["position"].(PositionComponent)
+		trap, _ := e.Components["trap"].(TrapComponent)
+
+		if distanceTo(pos.X, pos.Y, trapPos.X, trapPos.Y) > radius || !gameMap.IsVisibleToPlayer(trapPos.X, trapPos.Y) {
+			continue
+		}
+
+		if rand.Intn(100) < chance-trap.Difficulty {
+			revealTrap(e)
+			found++
+
+			trapApp, _ := e.Components["appearance"].(AppearanceComponent)
+			messageLog.Send(ui.CategorySystem, ui.SeverityGood, "You find a "+trapApp.ColoredName()+"!")
+		}
+	}
+
+	return found
+}
+
+func revealTrap(entity *GameEntity) {
+	trap, _ := entity.Components["trap"].(TrapComponent)
+	trap.Hidden = false
+
+	entity.RemoveComponent("trap")
+	entity.AddComponent("trap", trap)
+}
+
+func randomOpenTile(fromX, fromY int, entities []*GameEntity, gameMap *gamemap.Map) (int, int, bool) {
+	// Pick a random tile that something could safely stand on, out of those that can be walked to from the given
+	// location, without going through a locked door, or anything dangerous. That way, nothing can be sent somewhere
+	// it has no way back out of (the inside of a locked room, or a sealed vault).
+	reachable := gameMap.ReachableTiles(fromX, fromY, func(tile *gamemap.Tile) bool {
+		return !tile.IsWall() && tile.Type != gamemap.TileDoorLocked && !tile.IsHazardous()
+	})
+
+	var open []*gamemap.Tile
+	for _, tile := range reachable {
+		if !tile.Blocked && (tile.X != fromX || tile.Y != fromY) && GetBlockingEntitiesAtLocation(entities, tile.X, tile.Y) == nil {
+			open = append(open, tile)
+		}
+	}
+
+	if len(open) == 0 {
+		return 0, 0, false
+	}
+
+	tile := open[rand.Intn(len(open))]
+	return tile.X, tile.Y, true
+}
+
+func GetRevealedTrapAtLocation(entities []*GameEntity, x, y int) *GameEntity {
+	// Return the trap at the given location, if there is one, and it has been found
+	for _, e := range GetEntitiesPresentAtLocation(entities, x, y) {
+		if e.HasComponent("trap") {
+			return e
+		}
+	}
+	return nil
+}
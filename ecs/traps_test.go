@@ -0,0 +1,152 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"testing"
+)
+
+func newTrap(kind string, x, y int, hidden bool, difficulty int) *GameEntity {
+	return newEntity(map[string]Component{
+		"trap":       TrapComponent{Kind: kind, Hidden: hidden, Difficulty: difficulty},
+		"position":   PositionComponent{X: x, Y: y},
+		"appearance": AppearanceComponent{Name: "Trap"},
+	})
+}
+
+func newPlayer(x, y int) *GameEntity {
+	return newEntity(map[string]Component{
+		"player":     PlayerComponent{},
+		"position":   PositionComponent{X: x, Y: y},
+		"appearance": AppearanceComponent{Name: "Player"},
+		"hitpoints":  HitPointComponent{Hp: 100, MaxHP: 100},
+	})
+}
+
+func TestSystemSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		trapX      int
+		hidden     bool
+		difficulty int
+		chance     int
+		visible    bool
+		expect     int
+	}{
+		{"certain to be found", 6, true, 0, 100, true, 1},
+		{"too difficult to find", 6, true, 100, 100, true, 0},
+		{"no chance of finding it", 6, true, 0, 0, true, 0},
+		{"out of reach", 9, true, 0, 100, true, 0},
+		{"out of sight", 6, true, 0, 100, false, 0},
+		{"already found", 6, false, 0, 100, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gameMap := openMap(12, 12)
+			if test.visible {
+				gameMap.Visibility.Update(gameMap.FloorTiles())
+			}
+
+			player := newPlayer(5, 5)
+			trap := newTrap("dart", test.trapX, 5, test.hidden, test.difficulty)
+
+			found := SystemSearch(player, SearchRadius, test.chance, []*GameEntity{player, trap}, gameMap, newMessageLog())
+			if found != test.expect {
+				t.Errorf("expected to find %d traps, found %d", test.expect, found)
+			}
+
+			if test.expect > 0 && IsHiddenTrap(trap) {
+				t.Error("expected the trap to be revealed")
+			}
+		})
+	}
+}
+
+func TestSystemTriggerTraps(t *testing.T) {
+	tests := []struct {
+		kind  string
+		check func(t *testing.T, player, listener *GameEntity)
+	}{
+		{"pit", func(t *testing.T, player, listener *GameEntity) {
+			if !player.HasComponent("slowed") {
+				t.Error("expected the player to be slowed, climbing out of the pit")
+			}
+			if hp, _ := player.Components["hitpoints"].(HitPointComponent); hp.Hp >= hp.MaxHP {
+				t.Error("expected the player to be hurt by the fall")
+			}
+		}},
+		{"dart", func(t *testing.T, player, listener *GameEntity) {
+			if hp, _ := player.Components["hitpoints"].(HitPointComponent); hp.Hp >= hp.MaxHP {
+				t.Error("expected the player to be hurt by the dart")
+			}
+		}},
+		{"alarm", func(t *testing.T, player, listener *GameEntity) {
+			if perception, _ := listener.Components["perception"].(PerceptionComponent); perception.State != Alert {
+				t.Error("expected the alarm to wake the monster across the room")
+			}
+		}},
+		{"teleport", func(t *testing.T, player, listener *GameEntity) {
+			if pos, _ := player.Components["position"].(PositionComponent); pos.X == 5 && pos.Y == 5 {
+				t.Error("expected the player to be teleported away")
+			}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind, func(t *testing.T) {
+			gameMap := openMap(30, 12)
+			player := newPlayer(5, 5)
+			trap := newTrap(test.kind, 5, 5, true, 0)
+			listener := newEntity(map[string]Component{
+				"position":   PositionComponent{X: 25, Y: 5},
+				"perception": PerceptionComponent{SightRadius: 8, State: Asleep},
+			})
+
+			SystemTriggerTraps(player, []*GameEntity{player, trap, listener}, gameMap, newMessageLog())
+
+			if IsHiddenTrap(trap) {
+				t.Error("expected the trap to be revealed once it went off")
+			}
+			test.check(t, player, listener)
+		})
+	}
+}
+
+func TestSystemTriggerTrapsIgnoresMonsters(t *testing.T) {
+	// Monsters know where the traps are, and step around them
+	gameMap := openMap(10, 10)
+	monster := newEntity(map[string]Component{
+		"position":   PositionComponent{X: 5, Y: 5},
+		"appearance": AppearanceComponent{Name: "Orc"},
+		"hitpoints":  HitPointComponent{Hp: 10, MaxHP: 10},
+	})
+	trap := newTrap("dart", 5, 5, true, 0)
+
+	SystemTriggerTraps(monster, []*GameEntity{monster, trap}, gameMap, newMessageLog())
+
+	if !IsHiddenTrap(trap) {
+		t.Error("expected the trap to stay hidden")
+	}
+	if hp, _ := monster.Components["hitpoints"].(HitPointComponent); hp.Hp != hp.MaxHP {
+		t.Error("expected the monster not to be hurt")
+	}
+}
+
+func TestTeleportStaysInReach(t *testing.T) {
+	// A teleport trap never sends anyone into a sealed off part of the map
+	gameMap := openMap(20, 10)
+	for y := 1; y < gameMap.Height-1; y++ {
+		gameMap.Tiles[10][y].SetType(gamemap.TileWall)
+	}
+
+	for i := 0; i < 50; i++ {
+		player := newPlayer(5, 5)
+		trap := newTrap("teleport", 5, 5, false, 0)
+
+		SystemTriggerTraps(player, []*GameEntity{player, trap}, gameMap, newMessageLog())
+
+		if pos, _ := player.Components["position"].(PositionComponent); pos.X >= 10 {
+			t.Fatalf("the player was teleported to (%d, %d), on the far side of the wall", pos.X, pos.Y)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ecs
 
 import (
+	"bearrogue/gamemap"
 	"math"
 )
 
@@ -30,7 +31,7 @@ func GetEntitiesPresentAtLocation(entities []*GameEntity, x, y int) []*GameEntit
 			if e.HasComponents([]string{"position", "appearance"}) {
 				pos, _ := e.Components["position"].(PositionComponent)
 
-				if pos.X == x && pos.Y == y && !e.HasComponent("player") {
+				if pos.X == x && pos.Y == y && !e.HasComponent("player") && !IsHiddenTrap(e) {
 					// This entity is present at the currently examined location, so add its name to the list of present
 					// entities
 					entitiesPresent = append(entitiesPresent, e)
@@ -51,7 +52,7 @@ func GetEntityNamesPresentAtLocation(entities []*GameEntity, x, y int) string {
 				pos, _ := e.Components["position"].(PositionComponent)
 				appearance, _ := e.Components["appearance"].(AppearanceComponent)
 
-				if pos.X == x && pos.Y == y {
+				if pos.X == x && pos.Y == y && !IsHiddenTrap(e) {
 					// This entity is present at the currently examined location, so add its name to the list of present
 					// entities
 					entitiesPresent = append(entitiesPresent, appearance.Name)
@@ -95,6 +96,19 @@ func distanceTo(originX, originY, targetX, targetY int) int {
 
 }
 
+func rotateDirection(dx, dy, steps int) []int {
+	// Rotate a direction (each of dx and dy between -1 and 1) by 45 degrees per step. Positive steps rotate clockwise.
+	directions := [][]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+	for i, d := range directions {
+		if d[0] == dx && d[1] == dy {
+			return directions[(i+steps%8+8)%8]
+		}
+	}
+
+	return []int{dx, dy}
+}
+
 func Round(f float64) float64 {
 	return math.Floor(f + .5)
 }
@@ -181,3 +195,26 @@ func ItemsOwnedByEntity(entity *GameEntity, entities []*GameEntity) []*GameEntit
 
 	return ownedEntities
 }
+
+func PlaceNear(entity *GameEntity, x, y int, entities []*GameEntity, gameMap *gamemap.Map) bool {
+	// Move the entity to the closest open tile around the given location, looking a little further out each time if
+	// the nearby tiles are all taken. Returns false if nowhere could be found.
+	for radius := 1; radius <= 3; radius++ {
+		for dx := -radius; dx <= radius; dx++ {
+			for dy := -radius; dy <= radius; dy++ {
+				newX, newY := x+dx, y+dy
+
+				if newX <= 0 || newY <= 0 || newX >= gameMap.Width-1 || newY >= gameMap.Height-1 {
+					continue
+				}
+
+				if !gameMap.IsBlocked(newX, newY) && GetBlockingEntitiesAtLocation(entities, newX, newY) == nil {
+					entity.RemoveComponent("position")
+					entity.AddComponent("position", PositionComponent{X: newX, Y: newY})
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
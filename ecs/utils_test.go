@@ -0,0 +1,27 @@
+package ecs
+
+import (
+	"bearrogue/gamemap"
+	"bearrogue/ui"
+)
+
+func newEntity(components map[string]Component) *GameEntity {
+	entity := &GameEntity{}
+	entity.SetupGameEntity()
+	entity.AddComponents(components)
+	return entity
+}
+
+func openMap(width, height int) *gamemap.Map {
+	// An empty room, with bedrock around the edges
+	m := &gamemap.Map{Width: width, Height: height, Seed: 1}
+	m.InitializeMap()
+	m.GenerateArena()
+	return m
+}
+
+func newMessageLog() *ui.MessageLog {
+	messageLog := &ui.MessageLog{MaxLength: 100}
+	messageLog.InitMessages()
+	return messageLog
+}
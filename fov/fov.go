@@ -5,24 +5,57 @@ import (
 	"math"
 )
 
+type Caster interface {
+	// Cast returns every tile that can be seen from the origin, out to the given radius. The origin itself is always
+	// included. Casting does not change the map, it is up to the caller to mark the tiles it gets back.
+	Cast(originX, originY, radius int, gameMap *gamemap.Map) []*gamemap.Tile
+}
+
+func CasterByName(name string) Caster {
+	// Return a new field of view algorithm by name. Returns nil if there is no algorithm by that name.
+	switch name {
+	case "raycast":
+		return &RayCaster{}
+	case "shadowcast":
+		return &ShadowCaster{}
+	}
+	return nil
+}
+
 type FieldOfVision struct {
-	cosTable    map[int]float64
-	sinTable    map[int]float64
+	Algorithm   Caster
 	torchRadius int
+	sightRadius int
+
+	// The result of the last cast (every tile in line of sight), which is reused if nothing has changed since
+	lastMap           *gamemap.Map
+	lastX             int
+	lastY             int
+	lastRadius        int
+	lastRevision      int
+	lastLightRevision int
+	inSight           []*gamemap.Tile
+	visible           []*gamemap.Tile
 }
 
 func (f *FieldOfVision) Initialize() {
+	// Symmetric shadowcasting is used unless another algorithm has been chosen
+	if f.Algorithm == nil {
+		f.Algorithm = &ShadowCaster{}
+	}
+}
 
-	f.cosTable = make(map[int]float64)
-	f.sinTable = make(map[int]float64)
-
-	for i := 0; i < 360; i++ {
-		ax := math.Sin(float64(i) / (float64(180) / math.Pi))
-		ay := math.Cos(float64(i) / (float64(180) / math.Pi))
-
-		f.sinTable[i] = ax
-		f.cosTable[i] = ay
+func (f *FieldOfVision) SetAlgorithm(name string) bool {
+	// Switch to a different field of view algorithm, by name. Returns false, and leaves things as they were, if there is
+	// no algorithm by that name.
+	caster := CasterByName(name)
+	if caster == nil {
+		return false
 	}
+
+	f.Algorithm = caster
+	f.lastMap = nil
+	return true
 }
 
 func (f *FieldOfVision) SetTorchRadius(radius int) {
@@ -31,47 +64,67 @@ func (f *FieldOfVision) SetTorchRadius(radius int) {
 	}
 }
 
-func (f *FieldOfVision) RayCast(playerX, playerY int, gameMap *gamemap.Map) {
-	// Cast out rays each degree in a 360 circle from the player. If a ray passes over a floor (does not block sight)
-	// tile, keep going, up to the maximum torch radius (view radius) of the player. If the ray intersects a wall
-	// (blocks sight), stop, as the player will not be able to see past that. Every visible tile will get the Visible
-	// and Explored properties set to true.
+func (f *FieldOfVision) SetSightRadius(radius int) {
+	// How far away the player can make out lit areas. Anything closer than the torch radius can be seen whether it is
+	// lit or not.
+	if radius > 1 {
+		f.sightRadius = radius
+	}
+}
 
-	for i := 0; i < 360; i++ {
+func (f *FieldOfVision) radius() int {
+	if f.sightRadius > f.torchRadius {
+		return f.sightRadius
+	}
+	return f.torchRadius
+}
 
-		ax := f.sinTable[i]
-		ay := f.cosTable[i]
+func (f *FieldOfVision) Compute(playerX, playerY int, gameMap *gamemap.Map) {
+	// Work out which tiles the player can see from where they are standing, and record them in the maps visibility
+	// buffer. A tile can be seen if there is a clear line of sight to it, and it is either within the players torch
+	// radius, or lit by something else. Nothing is worked out again unless the player has moved, the terrain nearby has
+	// changed, or the lighting has.
+	radius := f.radius()
 
-		x := float64(playerX)
-		y := float64(playerY)
+	if f.isCached(playerX, playerY, gameMap) && f.lastLightRevision == gameMap.LightRevision {
+		return
+	}
 
-		// Mark the players current position as explored
-		gameMap.Tiles[playerX][playerY].Explored = true
-		gameMap.Tiles[playerX][playerY].Visible = true
+	if !f.isCached(playerX, playerY, gameMap) {
+		f.inSight = f.Algorithm.Cast(playerX, playerY, radius, gameMap)
+		f.lastMap, f.lastX, f.lastY, f.lastRadius, f.lastRevision = gameMap, playerX, playerY, radius, gameMap.Revision
+	}
 
-		for j := 0; j < f.torchRadius; j++ {
-			x -= ax
-			y -= ay
+	f.lastLightRevision = gameMap.LightRevision
 
-			roundedX := int(Round(x))
-			roundedY := int(Round(y))
+	f.visible = f.visible[:0]
+	for _, tile := range f.inSight {
+		dx, dy := tile.X-playerX, tile.Y-playerY
 
-			if x < 0 || x > float64(gameMap.Width-1) || y < 0 || y > float64(gameMap.Height-1) {
-				// If the ray is cast outside of the map, stop
-				break
-			}
+		if dx*dx+dy*dy <= f.torchRadius*f.torchRadius || tile.IsLit() {
+			f.visible = append(f.visible, tile)
+		}
+	}
 
-			gameMap.Tiles[roundedX][roundedY].Explored = true
-			gameMap.Tiles[roundedX][roundedY].Visible = true
+	gameMap.Visibility.Update(f.visible)
+}
 
-			//fmt.Printf("Actual X (float64): %d, rounded X (int): %d\n", x, int(Round(x)))
+func (f *FieldOfVision) isCached(x, y int, gameMap *gamemap.Map) bool {
+	// The last cast can be reused if it was made from the same spot, on the same map, with the same radius, and no tile
+	// close enough to have been seen has changed since (a door opening, or a wall being dug out, for example)
+	radius := f.radius()
 
-			if gameMap.Tiles[roundedX][roundedY].Blocks_sight == true {
-				// The ray hit a wall, go no further
-				break
-			}
+	if f.lastMap != gameMap || f.lastX != x || f.lastY != y || f.lastRadius != radius {
+		return false
+	}
+
+	for _, tile := range gameMap.ChangedSince(f.lastRevision) {
+		if abs(tile.X-x) <= radius && abs(tile.Y-y) <= radius {
+			return false
 		}
 	}
+
+	return true
 }
 
 func Round(f float64) float64 {
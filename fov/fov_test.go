@@ -0,0 +1,84 @@
+package fov
+
+import (
+	"bearrogue/gamemap"
+	"testing"
+)
+
+const (
+	benchmarkMapSize = 100
+	benchmarkRadius  = 20
+)
+
+func benchmarkMap() *gamemap.Map {
+	// An open 100x100 map, with pillars scattered regularly over it, so there are shadows to work out
+	m := &gamemap.Map{Width: benchmarkMapSize, Height: benchmarkMapSize, Seed: 1}
+	m.InitializeMap()
+	m.GenerateArena()
+
+	for x := 3; x < benchmarkMapSize-1; x += 7 {
+		for y := 2; y < benchmarkMapSize-1; y += 5 {
+			m.Tiles[x][y].SetType(gamemap.TileWall)
+		}
+	}
+
+	return m
+}
+
+func BenchmarkResetAndRecast(b *testing.B) {
+	// What was done before the visibility buffer: every frame, mark all 10,000 tiles as not visible, then cast rays out
+	// from the player again, and mark everything they reach
+	m := benchmarkMap()
+	caster := &RayCaster{}
+
+	visible := make([][]bool, m.Width)
+	for x := range visible {
+		visible[x] = make([]bool, m.Height)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for x := 0; x < m.Width; x++ {
+			for y := 0; y < m.Height; y++ {
+				visible[x][y] = false
+			}
+		}
+
+		for _, tile := range caster.Cast(50, 50, benchmarkRadius, m) {
+			visible[tile.X][tile.Y] = true
+			tile.Explored = true
+		}
+	}
+}
+
+func benchmarkCompute(b *testing.B, caster Caster, moving bool) {
+	m := benchmarkMap()
+	f := FieldOfVision{Algorithm: caster}
+	f.SetTorchRadius(benchmarkRadius)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		x := 50
+		if moving {
+			x += i % 2
+		}
+		f.Compute(x, 50, m)
+	}
+}
+
+func BenchmarkComputeStandingStill(b *testing.B) {
+	// Redrawing while the player stands still, which is most frames (menus, the examine cursor, and so on)
+	benchmarkCompute(b, &ShadowCaster{}, false)
+}
+
+func BenchmarkComputeMoving(b *testing.B) {
+	// The player moving back and forth, so the field of view has to be cast again every time
+	benchmarkCompute(b, &ShadowCaster{}, true)
+}
+
+func BenchmarkComputeMovingRayCast(b *testing.B) {
+	// As above, but with the old ray caster, to separate the cost of the algorithm from the cost of the bookkeeping
+	benchmarkCompute(b, &RayCaster{}, true)
+}
@@ -0,0 +1,141 @@
+package fov
+
+import "bearrogue/gamemap"
+
+type Point struct {
+	X int
+	Y int
+}
+
+func WalkLine(x0, y0, x1, y1 int, visit func(x, y int) bool) {
+	// Bresenhams line algorithm. Visits every point from (x0, y0) to (x1, y1), in order, including both end points.
+	// The walk stops early if visit returns false.
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+
+	for {
+		if !visit(x0, y0) {
+			return
+		}
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func Line(x0, y0, x1, y1 int) []Point {
+	// Return every point from (x0, y0) to (x1, y1), including both end points
+	var points []Point
+
+	WalkLine(x0, y0, x1, y1, func(x, y int) bool {
+		points = append(points, Point{X: x, Y: y})
+		return true
+	})
+
+	return points
+}
+
+func HasLineOfSight(originX, originY, targetX, targetY, radius int, gameMap *gamemap.Map) bool {
+	// Check to see if a viewer standing at the origin can see the target location. The target must be within the
+	// viewers sight radius, and no tile in between the two may block sight. The target tile itself is allowed to block
+	// sight, so that walls (and anything standing in a doorway) can still be seen.
+	dx := targetX - originX
+	dy := targetY - originY
+
+	if dx*dx+dy*dy > radius*radius {
+		return false
+	}
+
+	unobstructed := true
+
+	WalkLine(originX, originY, targetX, targetY, func(x, y int) bool {
+		if (x != originX || y != originY) && (x != targetX || y != targetY) && gameMap.Tiles[x][y].Blocks_sight {
+			unobstructed = false
+		}
+		return unobstructed
+	})
+
+	return unobstructed
+}
+
+func InRange(originX, originY, targetX, targetY, maxRange int) bool {
+	// Check that the target is within range of the origin. Range is counted in steps along the line between the two,
+	// the same way a shot travels, so a diagonal step counts the same as a straight one.
+	return abs(targetX-originX) <= maxRange && abs(targetY-originY) <= maxRange
+}
+
+func HasLineOfFire(originX, originY, targetX, targetY, maxRange int, gameMap *gamemap.Map, occupied func(x, y int) bool) bool {
+	// Check that a shot from the origin would reach the target location. The target must be within range, and nothing
+	// may be in the way: no tile that blocks sight or movement, and no tile the occupied function reports something
+	// standing on. What is at the origin and the target themselves does not matter.
+	if !InRange(originX, originY, targetX, targetY, maxRange) {
+		return false
+	}
+
+	unobstructed := true
+
+	WalkLine(originX, originY, targetX, targetY, func(x, y int) bool {
+		if (x != originX || y != originY) && (x != targetX || y != targetY) {
+			tile := gameMap.Tiles[x][y]
+			if tile.Blocks_sight || tile.Blocked || occupied(x, y) {
+				unobstructed = false
+			}
+		}
+		return unobstructed
+	})
+
+	return unobstructed
+}
+
+func LineOfFire(originX, originY, targetX, targetY, maxRange int, gameMap *gamemap.Map, occupied func(x, y int) bool) ([]Point, bool) {
+	// Trace the path a shot from the origin towards the target would take, out to its maximum range (counted the same
+	// way as InRange). The path stops just short of the first tile that blocks movement, or on the first occupied tile,
+	// in which case the second return value is true. The origin itself is not part of the path.
+	var path []Point
+	hit := false
+
+	WalkLine(originX, originY, targetX, targetY, func(x, y int) bool {
+		if x == originX && y == originY {
+			return true
+		}
+
+		if !InRange(originX, originY, x, y, maxRange) || gameMap.Tiles[x][y].Blocked {
+			return false
+		}
+
+		path = append(path, Point{X: x, Y: y})
+
+		hit = occupied(x, y)
+		return !hit
+	})
+
+	return path, hit
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
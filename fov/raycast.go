@@ -0,0 +1,67 @@
+package fov
+
+import (
+	"bearrogue/gamemap"
+	"math"
+)
+
+type RayCaster struct {
+	cosTable map[int]float64
+	sinTable map[int]float64
+}
+
+func (r *RayCaster) initialize() {
+	r.cosTable = make(map[int]float64)
+	r.sinTable = make(map[int]float64)
+
+	for i := 0; i < 360; i++ {
+		ax := math.Sin(float64(i) / (float64(180) / math.Pi))
+		ay := math.Cos(float64(i) / (float64(180) / math.Pi))
+
+		r.sinTable[i] = ax
+		r.cosTable[i] = ay
+	}
+}
+
+func (r *RayCaster) Cast(originX, originY, radius int, gameMap *gamemap.Map) []*gamemap.Tile {
+	// Cast out rays each degree in a 360 circle from the origin. If a ray passes over a floor (does not block sight)
+	// tile, keep going, up to the given radius. If the ray intersects a wall (blocks sight), stop, as nothing past that
+	// can be seen. This is quick, but rays spread apart the further out they go, so at larger radii some tiles can be
+	// missed, and what can be seen is not always symmetric.
+	if r.sinTable == nil {
+		r.initialize()
+	}
+
+	visible := []*gamemap.Tile{gameMap.Tiles[originX][originY]}
+
+	for i := 0; i < 360; i++ {
+
+		ax := r.sinTable[i]
+		ay := r.cosTable[i]
+
+		x := float64(originX)
+		y := float64(originY)
+
+		for j := 0; j < radius; j++ {
+			x -= ax
+			y -= ay
+
+			roundedX := int(Round(x))
+			roundedY := int(Round(y))
+
+			if x < 0 || x > float64(gameMap.Width-1) || y < 0 || y > float64(gameMap.Height-1) {
+				// If the ray is cast outside of the map, stop
+				break
+			}
+
+			visible = append(visible, gameMap.Tiles[roundedX][roundedY])
+
+			if gameMap.Tiles[roundedX][roundedY].Blocks_sight == true {
+				// The ray hit a wall, go no further
+				break
+			}
+		}
+	}
+
+	return visible
+}
@@ -0,0 +1,101 @@
+package fov
+
+import "bearrogue/gamemap"
+
+type ShadowCaster struct {
+	originX int
+	originY int
+	radius  int
+	gameMap *gamemap.Map
+	visible []*gamemap.Tile
+}
+
+// The four quadrants scanned outwards from the origin. Each one turns a (depth, column) position within the quadrant
+// into an offset on the map.
+var quadrants = [4]func(depth, col int) (int, int){
+	func(depth, col int) (int, int) { return col, -depth },
+	func(depth, col int) (int, int) { return col, depth },
+	func(depth, col int) (int, int) { return depth, col },
+	func(depth, col int) (int, int) { return -depth, col },
+}
+
+// A slope, kept as a fraction so that rounding at the edges of a row is exact
+type slope struct {
+	num int
+	den int
+}
+
+func (s *ShadowCaster) Cast(originX, originY, radius int, gameMap *gamemap.Map) []*gamemap.Tile {
+	// Symmetric recursive shadowcasting. Each quadrant is scanned one row at a time, moving away from the origin. Any
+	// wall found in a row casts a shadow over the rows behind it, which narrows (or splits) the part of the next row that
+	// still needs scanning. Floor tiles are only counted as visible if the origin would also be visible from them, so if
+	// the player can see a tile, anything standing on that tile can see the player too. Walls are always shown, if any
+	// part of them is lit, so rooms do not look like they have gaps in their edges.
+	s.originX, s.originY, s.radius, s.gameMap = originX, originY, radius, gameMap
+	s.visible = []*gamemap.Tile{gameMap.Tiles[originX][originY]}
+
+	for _, transform := range quadrants {
+		s.scan(transform, 1, slope{-1, 1}, slope{1, 1})
+	}
+
+	visible := s.visible
+	s.gameMap, s.visible = nil, nil
+	return visible
+}
+
+func (s *ShadowCaster) scan(transform func(depth, col int) (int, int), depth int, start, end slope) {
+	// Scan a single row of a quadrant, between the start and end slopes, recursing into the row beyond for every run of
+	// open tiles found
+	if depth > s.radius {
+		return
+	}
+
+	minCol := floorDiv(2*depth*start.num+start.den, 2*start.den)
+	maxCol := -floorDiv(-(2*depth*end.num - end.den), 2*end.den)
+
+	previousWall, first := false, true
+
+	for col := minCol; col <= maxCol; col++ {
+		dx, dy := transform(depth, col)
+		tile := s.tileAt(s.originX+dx, s.originY+dy)
+		wall := tile == nil || tile.Blocks_sight
+
+		if tile != nil && dx*dx+dy*dy <= s.radius*s.radius {
+			symmetric := col*start.den >= depth*start.num && col*end.den <= depth*end.num
+			if wall || symmetric {
+				s.visible = append(s.visible, tile)
+			}
+		}
+
+		if !first && previousWall && !wall {
+			// Coming out from behind a wall, the open part of the row starts here
+			start = slope{2*col - 1, 2 * depth}
+		}
+		if !first && !previousWall && wall {
+			// Running into a wall, so everything up to here can see into the next row
+			s.scan(transform, depth+1, start, slope{2*col - 1, 2 * depth})
+		}
+
+		previousWall, first = wall, false
+	}
+
+	if !first && !previousWall {
+		s.scan(transform, depth+1, start, end)
+	}
+}
+
+func (s *ShadowCaster) tileAt(x, y int) *gamemap.Tile {
+	// Return the tile at the given location, or nil if it is off the edge of the map
+	if x < 0 || y < 0 || x >= s.gameMap.Width || y >= s.gameMap.Height {
+		return nil
+	}
+	return s.gameMap.Tiles[x][y]
+}
+
+func floorDiv(a, b int) int {
+	// Integer division that always rounds down, rather than towards zero. b must be positive.
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
@@ -0,0 +1,280 @@
+package fov
+
+import (
+	"bearrogue/gamemap"
+	"strings"
+	"testing"
+)
+
+// Reference maps. # is a wall, . is floor, and @ marks where the viewer stands when checking for gaps.
+var referenceMaps = map[string][]string{
+	"open room": {
+		"#########################",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#...........@...........#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#.......................#",
+		"#########################",
+	},
+	"pillar": {
+		"#################",
+		"#...............#",
+		"#...............#",
+		"#...............#",
+		"#...............#",
+		"#......###......#",
+		"#......###......#",
+		"#...@..###......#",
+		"#...............#",
+		"#...............#",
+		"#...............#",
+		"#################",
+	},
+	"corridor": {
+		"###########################",
+		"#.......#################.#",
+		"#.......#################.#",
+		"#..@......................#",
+		"#.......#################.#",
+		"#.......#################.#",
+		"###########################",
+	},
+}
+
+const testRadius = 10
+
+// Rays cast at one degree steps only start to spread far enough apart to miss whole tiles at larger radii, so gaps are
+// also checked for in a much larger room than the ones above
+const largeRadius = 40
+
+func openRoom(size int) []string {
+	// Build a square room of the given size, with walls around the outside, and the viewer in the middle
+	rows := []string{strings.Repeat("#", size)}
+
+	for y := 1; y < size-1; y++ {
+		row := "#" + strings.Repeat(".", size-2) + "#"
+		if y == size/2 {
+			row = row[:size/2] + "@" + row[size/2+1:]
+		}
+		rows = append(rows, row)
+	}
+
+	return append(rows, strings.Repeat("#", size))
+}
+
+func parseMap(t *testing.T, rows []string) (*gamemap.Map, int, int) {
+	// Build a map from one of the reference maps, returning it along with where the viewer stands. Short rows are
+	// padded out with wall.
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	m := &gamemap.Map{Width: width, Height: len(rows), Seed: 1}
+	m.InitializeMap()
+
+	originX, originY := -1, -1
+
+	for y, row := range rows {
+		row += strings.Repeat("#", width-len(row))
+		for x, glyph := range row {
+			tileType := gamemap.TileFloor
+			if glyph == '#' {
+				tileType = gamemap.TileWall
+			}
+
+			m.Tiles[x][y] = &gamemap.Tile{X: x, Y: y, Stairs: gamemap.NoStairs}
+			m.Tiles[x][y].SetType(tileType)
+
+			if glyph == '@' {
+				originX, originY = x, y
+			}
+		}
+	}
+
+	if originX < 0 {
+		t.Fatal("reference map has no viewer")
+	}
+
+	return m, originX, originY
+}
+
+func castSet(caster Caster, x, y, radius int, m *gamemap.Map) map[*gamemap.Tile]bool {
+	seen := map[*gamemap.Tile]bool{}
+	for _, tile := range caster.Cast(x, y, radius, m) {
+		seen[tile] = true
+	}
+	return seen
+}
+
+func gaps(caster Caster, rows []string, radius int, t *testing.T) []*gamemap.Tile {
+	// Return every floor tile within the radius of the viewer, in plain view (a straight line from the middle of the
+	// viewers tile to the middle of it does not pass through any wall), that the caster missed
+	m, originX, originY := parseMap(t, rows)
+	seen := castSet(caster, originX, originY, radius, m)
+
+	var missed []*gamemap.Tile
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			tile := m.Tiles[x][y]
+			dx, dy := x-originX, y-originY
+
+			if tile.Blocks_sight || seen[tile] || dx*dx+dy*dy > radius*radius {
+				continue
+			}
+
+			if inPlainView(m, originX, originY, x, y) {
+				missed = append(missed, tile)
+			}
+		}
+	}
+
+	return missed
+}
+
+func inPlainView(m *gamemap.Map, x0, y0, x1, y1 int) bool {
+	// Check that the straight line between the middles of two tiles does not pass through the inside of any wall.
+	// Only touching the edge, or the corner, of a wall does not count.
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[x][y].Blocks_sight && crossesSquare(x0, y0, x1, y1, x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func crossesSquare(x0, y0, x1, y1, squareX, squareY int) bool {
+	// Clip the line from (x0, y0) to (x1, y1) against the square centered on (squareX, squareY), and check whether any
+	// of it is left strictly inside
+	const epsilon = 1e-9
+	enter, exit := 0.0, 1.0
+
+	for _, axis := range [][3]float64{
+		{float64(x0), float64(x1 - x0), float64(squareX)},
+		{float64(y0), float64(y1 - y0), float64(squareY)},
+	} {
+		start, delta, center := axis[0], axis[1], axis[2]
+
+		if delta == 0 {
+			if start <= center-0.5+epsilon || start >= center+0.5-epsilon {
+				return false
+			}
+			continue
+		}
+
+		near, far := (center-0.5-start)/delta, (center+0.5-start)/delta
+		if near > far {
+			near, far = far, near
+		}
+		if near > enter {
+			enter = near
+		}
+		if far < exit {
+			exit = far
+		}
+	}
+
+	return exit-enter > epsilon
+}
+
+func asymmetries(caster Caster, rows []string, t *testing.T) int {
+	// Count the pairs of floor tiles where one can see the other, but not the other way around
+	m, _, _ := parseMap(t, rows)
+
+	floors := []*gamemap.Tile{}
+	seen := map[*gamemap.Tile]map[*gamemap.Tile]bool{}
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if !m.Tiles[x][y].Blocks_sight {
+				floors = append(floors, m.Tiles[x][y])
+				seen[m.Tiles[x][y]] = castSet(caster, x, y, testRadius, m)
+			}
+		}
+	}
+
+	count := 0
+	for i, a := range floors {
+		for _, b := range floors[i+1:] {
+			if seen[a][b] != seen[b][a] {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestShadowCastHasNoGaps(t *testing.T) {
+	for name, rows := range referenceMaps {
+		for _, tile := range gaps(&ShadowCaster{}, rows, testRadius, t) {
+			t.Errorf("%s: tile (%d, %d) is in plain view, but was not seen", name, tile.X, tile.Y)
+		}
+	}
+
+	for _, tile := range gaps(&ShadowCaster{}, openRoom(2*largeRadius+3), largeRadius, t) {
+		t.Errorf("large room: tile (%d, %d) is in plain view, but was not seen", tile.X, tile.Y)
+	}
+}
+
+func TestShadowCastIsSymmetric(t *testing.T) {
+	for name, rows := range referenceMaps {
+		if count := asymmetries(&ShadowCaster{}, rows, t); count > 0 {
+			t.Errorf("%s: %d pairs of tiles can only see each other one way", name, count)
+		}
+	}
+}
+
+func TestShadowCastSeesWalls(t *testing.T) {
+	// The walls around the open room should all be visible from the middle of it, out to the radius
+	m, originX, originY := parseMap(t, referenceMaps["open room"])
+	seen := castSet(&ShadowCaster{}, originX, originY, m.Width, m)
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[x][y].Blocks_sight && !seen[m.Tiles[x][y]] {
+				t.Errorf("wall at (%d, %d) was not seen", x, y)
+			}
+		}
+	}
+}
+
+func TestRayCastHasGapsAndAsymmetries(t *testing.T) {
+	// The old ray caster is what shadowcasting replaced. If it did not show the same problems on the reference maps,
+	// the tests above would not be proving very much.
+	totalAsymmetries := 0
+
+	for _, rows := range referenceMaps {
+		totalAsymmetries += asymmetries(&RayCaster{}, rows, t)
+	}
+
+	if len(gaps(&RayCaster{}, openRoom(2*largeRadius+3), largeRadius, t)) == 0 {
+		t.Error("expected the ray caster to miss some tiles in plain view")
+	}
+	if totalAsymmetries == 0 {
+		t.Error("expected the ray caster to see some tiles one way only")
+	}
+}
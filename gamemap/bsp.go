@@ -0,0 +1,172 @@
+package gamemap
+
+import (
+	"math/rand"
+)
+
+const (
+	// Corridor styles, for generators that join rooms together
+	CorridorStraight = iota
+	CorridorLShaped
+	CorridorWinding
+)
+
+type bspLeaf struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+	left   *bspLeaf
+	right  *bspLeaf
+	room   int
+}
+
+func (m *Map) GenerateBSP(minLeafSize int, roomFillRatio float64, corridorStyle int) ([]Rect, [][]int) {
+	// Generate a dungeon using binary space partitioning. The map is split in two, and each half split again, until
+	// the pieces (leaves) would be smaller than the minimum leaf size. A room is placed in each leaf, taking up
+	// roughly the given fraction (0 to 1) of it, and then rooms are joined back up in the same order the map was split,
+	// so every room can be reached. Returns the rooms, and their connectivity graph (see GenerateRoomsAndCorridors).
+	if minLeafSize < 5 {
+		minLeafSize = 5
+	}
+
+	if roomFillRatio <= 0 || roomFillRatio > 1 {
+		roomFillRatio = 0.75
+	}
+
+	m.fillWithWalls()
+
+	// Step 1: Split the map up. The outer edge of the map is left alone, as it is always wall.
+	root := &bspLeaf{X: 1, Y: 1, Width: m.Width - 2, Height: m.Height - 2, room: -1}
+	root.split(minLeafSize)
+
+	// Step 2: Place a room in every leaf at the bottom of the tree
+	var rooms []Rect
+	root.createRooms(m, roomFillRatio, &rooms)
+
+	// Step 3: Join the two halves of every split together, working back up the tree
+	graph := make([][]int, len(rooms))
+	root.connect(m, rooms, graph, corridorStyle)
+
+	return rooms, graph
+}
+
+func (l *bspLeaf) split(minLeafSize int) {
+	// Split this leaf in two, and then split each of those, for as long as there is room to do so
+	canSplitWide := l.Width >= minLeafSize*2
+	canSplitHigh := l.Height >= minLeafSize*2
+
+	if !canSplitWide && !canSplitHigh {
+		return
+	}
+
+	// Prefer to split across the longest side, so leaves do not end up long and thin
+	splitWide := canSplitWide
+	if canSplitWide && canSplitHigh {
+		if l.Width > l.Height*5/4 {
+			splitWide = true
+		} else if l.Height > l.Width*5/4 {
+			splitWide = false
+		} else {
+			splitWide = rand.Intn(2) == 0
+		}
+	}
+
+	if splitWide {
+		at := minLeafSize + rand.Intn(l.Width-minLeafSize*2+1)
+		l.left = &bspLeaf{X: l.X, Y: l.Y, Width: at, Height: l.Height, room: -1}
+		l.right = &bspLeaf{X: l.X + at, Y: l.Y, Width: l.Width - at, Height: l.Height, room: -1}
+	} else {
+		at := minLeafSize + rand.Intn(l.Height-minLeafSize*2+1)
+		l.left = &bspLeaf{X: l.X, Y: l.Y, Width: l.Width, Height: at, room: -1}
+		l.right = &bspLeaf{X: l.X, Y: l.Y + at, Width: l.Width, Height: l.Height - at, room: -1}
+	}
+
+	l.left.split(minLeafSize)
+	l.right.split(minLeafSize)
+}
+
+func (l *bspLeaf) createRooms(m *Map, fillRatio float64, rooms *[]Rect) {
+	if l.left != nil {
+		l.left.createRooms(m, fillRatio, rooms)
+		l.right.createRooms(m, fillRatio, rooms)
+		return
+	}
+
+	// Leave a wall between this leaf and its neighbors. Rooms vary a little in size, up to the size of the leaf.
+	maxWidth, maxHeight := l.Width-2, l.Height-2
+
+	width := randomRoomSize(maxWidth, fillRatio)
+	height := randomRoomSize(maxHeight, fillRatio)
+
+	x := l.X + 1 + rand.Intn(maxWidth-width+1)
+	y := l.Y + 1 + rand.Intn(maxHeight-height+1)
+
+	room := Rect{X1: x, Y1: y, X2: x + width - 1, Y2: y + height - 1}
+	m.carveRoom(room)
+
+	l.room = len(*rooms)
+	*rooms = append(*rooms, room)
+}
+
+func randomRoomSize(max int, fillRatio float64) int {
+	// Pick a size somewhere around the fill ratio of the maximum size
+	size := int(float64(max) * fillRatio)
+	size += rand.Intn(3) - 1
+
+	if size < 3 {
+		size = 3
+	}
+	if size > max {
+		size = max
+	}
+	return size
+}
+
+func (l *bspLeaf) connect(m *Map, rooms []Rect, graph [][]int, corridorStyle int) {
+	if l.left == nil {
+		return
+	}
+
+	l.left.connect(m, rooms, graph, corridorStyle)
+	l.right.connect(m, rooms, graph, corridorStyle)
+
+	// Join the closest pair of rooms across the two halves of this split
+	a, b := closestPair(rooms, l.left.roomIndices(), l.right.roomIndices())
+
+	x1, y1 := rooms[a].Center()
+	x2, y2 := rooms[b].Center()
+	m.digCorridor(x1, y1, x2, y2, corridorStyle)
+
+	graph[a] = append(graph[a], b)
+	graph[b] = append(graph[b], a)
+}
+
+func (l *bspLeaf) roomIndices() []int {
+	// Return the index of every room in this part of the tree
+	if l.left == nil {
+		return []int{l.room}
+	}
+	return append(l.left.roomIndices(), l.right.roomIndices()...)
+}
+
+func closestPair(rooms []Rect, first, second []int) (int, int) {
+	// Find the pair of rooms, one from each list, whose centers are closest together
+	bestA, bestB := first[0], second[0]
+	bestDistance := -1
+
+	for _, a := range first {
+		ax, ay := rooms[a].Center()
+
+		for _, b := range second {
+			bx, by := rooms[b].Center()
+			distance := (bx-ax)*(bx-ax) + (by-ay)*(by-ay)
+
+			if bestDistance < 0 || distance < bestDistance {
+				bestA, bestB, bestDistance = a, b, distance
+			}
+		}
+	}
+
+	return bestA, bestB
+}
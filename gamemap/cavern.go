@@ -6,16 +6,63 @@ import (
 )
 
 func (m *Map) GenerateCavern() []*Tile {
+	// Generate a cave system, keeping only the largest cavern as the play area
+	m.growCaves()
+	return m.keepLargestCavern(m.findCaverns())
+}
+
+func (m *Map) GenerateConnectedCavern(minCavernSize int, smoothingPasses int) []*Tile {
+	// Generate a cave system, but rather than filling in every cavern but the largest, tunnel from each of the others
+	// to the closest part of the main cavern. Caverns with fewer tiles than the minimum size are still filled in, as
+	// they are not worth the trip. The whole map is then smoothed a few more times, to wear the tunnels into the
+	// surrounding cave.
+	m.growCaves()
+
+	caverns := m.findCaverns()
+	sort.Sort(BySize(caverns))
+	mainCave := caverns[len(caverns)-1]
+
+	for i := len(caverns) - 2; i >= 0; i-- {
+		if len(caverns[i]) < minCavernSize {
+			continue
+		}
+
+		from, to := closestTiles(caverns[i], mainCave)
+		m.digTunnel(from.X, from.Y, to.X, to.Y)
+
+		// Once connected, the cavern is part of the main one, and later caverns may tunnel into it instead
+		mainCave = append(mainCave, caverns[i]...)
+	}
+
+	for i := 0; i < smoothingPasses; i++ {
+		for x := 1; x < m.Width-1; x++ {
+			for y := 1; y < m.Height-1; y++ {
+				wallOneAway := m.countWallsNStepsAway(1, x, y)
 
+				if wallOneAway >= 5 {
+					m.Tiles[x][y].SetType(TileWall)
+				} else {
+					m.Tiles[x][y].SetType(TileFloor)
+				}
+			}
+		}
+	}
+
+	// Smoothing may have pinched off a tunnel here or there, or left a few stray pockets behind, so find the caverns
+	// again. Anything that is no longer attached to the main cavern is filled in.
+	return m.keepLargestCavern(m.findCaverns())
+}
+
+func (m *Map) growCaves() {
 	// Step 1: Fill the map space with a random assortment of walls and floors. This uses a roughly 40/60 ratio in favor
 	// of floors, as I've found that to produce the nicest results.
 	for x := 0; x < m.Width; x++ {
 		for y := 0; y < m.Height; y++ {
 			state := rand.Intn(100)
 			if state < 50 {
-				m.Tiles[x][y] = &Tile{true, true, false, false, false, x, y}
+				m.Tiles[x][y] = newTile(x, y, TileWall)
 			} else {
-				m.Tiles[x][y] = &Tile{false, false, false, false, false, x, y}
+				m.Tiles[x][y] = newTile(x, y, TileFloor)
 			}
 		}
 	}
@@ -32,11 +79,9 @@ func (m *Map) GenerateCavern() []*Tile {
 				wallTwoAway := m.countWallsNStepsAway(2, x, y)
 
 				if wallOneAway >= 5 || wallTwoAway <= 2 {
-					m.Tiles[x][y].Blocked = true
-					m.Tiles[x][y].Blocks_sight = true
+					m.Tiles[x][y].SetType(TileWall)
 				} else {
-					m.Tiles[x][y].Blocked = false
-					m.Tiles[x][y].Blocks_sight = false
+					m.Tiles[x][y].SetType(TileFloor)
 				}
 			}
 		}
@@ -49,32 +94,28 @@ func (m *Map) GenerateCavern() []*Tile {
 				wallOneAway := m.countWallsNStepsAway(1, x, y)
 
 				if wallOneAway >= 5 {
-					m.Tiles[x][y].Blocked = true
-					m.Tiles[x][y].Blocks_sight = true
+					m.Tiles[x][y].SetType(TileWall)
 				} else {
-					m.Tiles[x][y].Blocked = false
-					m.Tiles[x][y].Blocks_sight = false
+					m.Tiles[x][y].SetType(TileFloor)
 				}
 			}
 		}
 	}
 
-	// Step 4: Seal up the edges of the map, so the player, and the following flood fill passes, cannot go beyond the
-	// intended game area
+	// Step 4: Seal up the edges of the map with bedrock, so the player, and the following flood fill passes, cannot go
+	// beyond the intended game area. Unlike the rest of the walls, bedrock can never be dug through.
+	m.sealEdges()
+
+}
+
+func (m *Map) findCaverns() [][]*Tile {
+	// Flood fill. This will find each individual cavern in the cave system, and return them as a list.
 	for x := 0; x < m.Width; x++ {
 		for y := 0; y < m.Height; y++ {
-			if x == 0 || x == m.Width-1 || y == 0 || y == m.Height-1 {
-				m.Tiles[x][y].Blocked = true
-				m.Tiles[x][y].Blocks_sight = true
-			}
+			m.Tiles[x][y].Visited = false
 		}
 	}
 
-	// Step 5: Flood fill. This will find each individual cavern in the cave system, and add them to a list. It will
-	// then find the largest one, and will make that as the main play area. The smaller caverns will be filled in.
-	// In the future, it might make sense to tunnel between caverns, and apply a few more smoothing passes, to make
-	// larger, more realistic caverns.
-
 	var cavern []*Tile
 	var totalCavernArea []*Tile
 	var caverns [][]*Tile
@@ -133,6 +174,10 @@ func (m *Map) GenerateCavern() []*Tile {
 		}
 	}
 
+	return caverns
+}
+
+func (m *Map) keepLargestCavern(caverns [][]*Tile) []*Tile {
 	// Sort the caverns slice by size. This will make the largest cavern last, which will then be removed from the list.
 	// Then, fill in any remaining caverns (aside from the main one). This will ensure that there are no areas on the
 	// map that the player cannot reach.
@@ -142,8 +187,7 @@ func (m *Map) GenerateCavern() []*Tile {
 
 	for i := 0; i < len(caverns); i++ {
 		for j := 0; j < len(caverns[i]); j++ {
-			caverns[i][j].Blocked = true
-			caverns[i][j].Blocks_sight = true
+			caverns[i][j].SetType(TileWall)
 		}
 	}
 
@@ -159,7 +203,7 @@ func (m *Map) countWallsNStepsAway(n int, x int, y int) int {
 			if x+r >= m.Width || x+r <= 0 || y+c >= m.Height || y+c <= 0 {
 				// Check if the current coordinates would be off the map. Off map coordinates count as a wall.
 				wallCount++
-			} else if m.Tiles[x+r][y+c].Blocked && m.Tiles[x+r][y+c].Blocks_sight {
+			} else if m.Tiles[x+r][y+c].IsWall() {
 				wallCount++
 			}
 		}
@@ -167,3 +211,40 @@ func (m *Map) countWallsNStepsAway(n int, x int, y int) int {
 
 	return wallCount
 }
+
+func (m *Map) digTunnel(x1, y1, x2, y2 int) {
+	// Dig an L-shaped tunnel, two tiles wide, between two points. Two tiles is enough to survive being smoothed.
+	m.carveHorizontal(x1, x2, y1)
+	m.carveHorizontal(x1, x2, y1+1)
+	m.carveVertical(y1, y2, x2)
+	m.carveVertical(y1, y2, x2+1)
+}
+
+func closestTiles(first, second []*Tile) (*Tile, *Tile) {
+	// Find the pair of tiles, one from each list, that are closest together
+	bestA, bestB := first[0], second[0]
+	bestDistance := -1
+
+	for _, a := range first {
+		for _, b := range second {
+			distance := (b.X-a.X)*(b.X-a.X) + (b.Y-a.Y)*(b.Y-a.Y)
+
+			if bestDistance < 0 || distance < bestDistance {
+				bestA, bestB, bestDistance = a, b, distance
+			}
+		}
+	}
+
+	return bestA, bestB
+}
+
+func (m *Map) sealEdges() {
+	// Turn the outer edge of the map into bedrock
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if x == 0 || x == m.Width-1 || y == 0 || y == m.Height-1 {
+				m.Tiles[x][y].SetType(TileBedrock)
+			}
+		}
+	}
+}
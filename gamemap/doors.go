@@ -0,0 +1,124 @@
+package gamemap
+
+import (
+	"math/rand"
+)
+
+func (m *Map) PlaceDoors(rooms []Rect, percent int) []*Tile {
+	// Put doors in some of the doorways leading into each room. A doorway is a gap in the wall around a room, with
+	// wall on either side of it, so a door fits neatly. Each doorway has the given percent chance of getting a door,
+	// which is usually closed, but sometimes left open. Returns every door placed.
+	var doors []*Tile
+
+	for _, room := range rooms {
+		for _, tile := range m.doorways(room) {
+			if rand.Intn(100) >= percent {
+				continue
+			}
+
+			if rand.Intn(3) == 0 {
+				tile.SetType(TileDoorOpen)
+			} else {
+				tile.SetType(TileDoorClosed)
+			}
+			doors = append(doors, tile)
+		}
+	}
+
+	return doors
+}
+
+func (m *Map) LockRoom(room Rect) bool {
+	// Lock every door leading into the room. Doorways without a door are given a locked one, so the room is sealed.
+	// Corridors between other rooms sometimes run through a room's doorways, so if locking it would cut the stairs down
+	// off from the stairs up, the room is left as it was. Returns whether the room was locked.
+	doorways := m.doorways(room)
+	previous := make([]int, len(doorways))
+
+	for i, tile := range doorways {
+		previous[i] = tile.Type
+		tile.SetType(TileDoorLocked)
+	}
+
+	if m.StairsConnected() {
+		return true
+	}
+
+	for i, tile := range doorways {
+		tile.SetType(previous[i])
+	}
+	return false
+}
+
+func (m *Map) StairsConnected() bool {
+	// Check that the stairs down can be walked to from the stairs up, without needing a key for any locked door
+	upX, upY, upOk := m.FindStairs(StairsUp)
+	downX, downY, downOk := m.FindStairs(StairsDown)
+
+	if !upOk || !downOk {
+		return true
+	}
+
+	reachable := m.ReachableTiles(upX, upY, func(tile *Tile) bool {
+		return !tile.IsWall() && tile.Type != TileDoorLocked
+	})
+
+	for _, tile := range reachable {
+		if tile.X == downX && tile.Y == downY {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Map) LockedDoors() []*Tile {
+	var doors []*Tile
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[x][y].Type == TileDoorLocked {
+				doors = append(doors, m.Tiles[x][y])
+			}
+		}
+	}
+
+	return doors
+}
+
+func (m *Map) doorways(room Rect) []*Tile {
+	// Find every doorway in the wall around the room (doors already in place count as doorways)
+	var doorways []*Tile
+
+	isDoorway := func(x, y, sideX, sideY int) bool {
+		if x <= 0 || y <= 0 || x >= m.Width-1 || y >= m.Height-1 {
+			return false
+		}
+
+		tile := m.Tiles[x][y]
+		if (tile.Type != TileFloor && !tile.IsDoor()) || tile.Stairs != NoStairs {
+			return false
+		}
+
+		return m.Tiles[x-sideX][y-sideY].IsWall() && m.Tiles[x+sideX][y+sideY].IsWall()
+	}
+
+	for x := room.X1; x <= room.X2; x++ {
+		// The walls above and below the room, where a doorway has walls to its left and right
+		for _, y := range []int{room.Y1 - 1, room.Y2 + 1} {
+			if isDoorway(x, y, 1, 0) {
+				doorways = append(doorways, m.Tiles[x][y])
+			}
+		}
+	}
+
+	for y := room.Y1; y <= room.Y2; y++ {
+		// The walls to either side of the room, where a doorway has walls above and below it
+		for _, x := range []int{room.X1 - 1, room.X2 + 1} {
+			if isDoorway(x, y, 0, 1) {
+				doorways = append(doorways, m.Tiles[x][y])
+			}
+		}
+	}
+
+	return doorways
+}
@@ -0,0 +1,86 @@
+package gamemap
+
+import "testing"
+
+func walledMap(width, height int) *Map {
+	// A map that is solid wall, apart from its bedrock edges
+	m := &Map{Width: width, Height: height, Seed: 1}
+	m.InitializeMap()
+	m.GenerateArena()
+
+	for x := 1; x < width-1; x++ {
+		for y := 1; y < height-1; y++ {
+			m.Tiles[x][y].SetType(TileWall)
+		}
+	}
+	return m
+}
+
+func TestReachableTilesDiagonally(t *testing.T) {
+	// Things can squeeze diagonally between two walls, so tiles that only touch at the corners are still connected
+	m := walledMap(8, 8)
+	for i := 2; i <= 4; i++ {
+		m.Tiles[i][i].SetType(TileFloor)
+	}
+	m.Tiles[2][2].Stairs = StairsUp
+	m.Tiles[4][4].Stairs = StairsDown
+
+	reachable := m.ReachableTiles(2, 2, func(tile *Tile) bool {
+		return !tile.IsWall()
+	})
+
+	if len(reachable) != 3 {
+		t.Errorf("expected 3 reachable tiles, got %d", len(reachable))
+	}
+
+	if !m.StairsConnected() {
+		t.Error("the stairs should be connected, along the diagonal")
+	}
+
+	if path := m.FindPath(2, 2, 4, 4, 100, func(tile *Tile) bool { return !tile.IsWall() }); len(path) != 2 {
+		t.Errorf("expected a path of 2 steps between the stairs, got %d", len(path))
+	}
+}
+
+func TestLockRoom(t *testing.T) {
+	// A room on the right hand side of the map, with a single doorway leading into it from the left
+	room := Rect{X1: 11, Y1: 1, X2: 18, Y2: 7}
+
+	tests := []struct {
+		name          string
+		downX         int
+		expectLocked  bool
+		expectDoorway int
+	}{
+		{"stairs outside the room", 5, true, TileDoorLocked},
+		{"stairs inside the room", 15, false, TileFloor},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Map{Width: 20, Height: 9, Seed: 1}
+			m.InitializeMap()
+			m.GenerateArena()
+
+			for y := 1; y < m.Height-1; y++ {
+				m.Tiles[10][y].SetType(TileWall)
+			}
+			m.Tiles[10][4].SetType(TileFloor)
+
+			m.Tiles[2][4].Stairs = StairsUp
+			m.Tiles[test.downX][4].Stairs = StairsDown
+
+			if locked := m.LockRoom(room); locked != test.expectLocked {
+				t.Errorf("expected LockRoom to return %v, got %v", test.expectLocked, locked)
+			}
+
+			if m.Tiles[10][4].Type != test.expectDoorway {
+				t.Errorf("expected the doorway to be tile type %d, got %d", test.expectDoorway, m.Tiles[10][4].Type)
+			}
+
+			if !m.StairsConnected() {
+				t.Error("the stairs should still be connected")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package gamemap
+
+import (
+	"math/rand"
+)
+
+func (m *Map) GenerateDrunkardsWalk(coverage float64) []*Tile {
+	// Generate winding, organic passages by letting a "drunk" wander the map at random, digging out every tile they
+	// step on. Each drunk only walks so far before giving up, and the next one starts from somewhere that has already
+	// been dug, so every floor tile stays connected. This carries on until the given fraction (0 to 1) of the map has
+	// been dug out.
+	if coverage <= 0 || coverage > 0.9 {
+		coverage = 0.4
+	}
+
+	m.fillWithWalls()
+
+	x, y := m.Width/2, m.Height/2
+	m.carve(x, y)
+	floors := []*Tile{m.Tiles[x][y]}
+
+	target := int(float64((m.Width-2)*(m.Height-2)) * coverage)
+	maxSteps := (m.Width + m.Height) * 2
+
+	// Put an upper limit on how long to keep trying, in case the map is too small to ever reach the target
+	for attempts := 0; len(floors) < target && attempts < target*2; attempts++ {
+		start := floors[rand.Intn(len(floors))]
+		x, y = start.X, start.Y
+
+		for step := 0; step < maxSteps && len(floors) < target; step++ {
+			switch rand.Intn(4) {
+			case 0:
+				x++
+			case 1:
+				x--
+			case 2:
+				y++
+			default:
+				y--
+			}
+
+			// Bounce off the edges of the map, as they are always wall
+			if x <= 0 || y <= 0 || x >= m.Width-1 || y >= m.Height-1 {
+				x, y = clamp(x, 1, m.Width-2), clamp(y, 1, m.Height-2)
+				continue
+			}
+
+			if m.Tiles[x][y].IsWall() {
+				m.carve(x, y)
+				floors = append(floors, m.Tiles[x][y])
+			}
+		}
+	}
+
+	// Clear out any lone pillars of wall the drunks have left standing in the middle of open floor. The tile itself
+	// counts as one of the walls, so a count of one means there are no walls around it at all.
+	for x := 1; x < m.Width-1; x++ {
+		for y := 1; y < m.Height-1; y++ {
+			if m.Tiles[x][y].IsWall() && m.countWallsNStepsAway(1, x, y) == 1 {
+				m.carve(x, y)
+			}
+		}
+	}
+
+	return m.FloorTiles()
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
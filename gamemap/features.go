@@ -0,0 +1,172 @@
+package gamemap
+
+import (
+	"math/rand"
+)
+
+func (m *Map) AddTerrainFeatures(depth int) {
+	// Scatter some natural features over the open floor of a generated map: pools of water (deep in the middle, and
+	// shallow around the edges), patches of grass, heaps of rubble, and glowing fungus. Deeper down, some of the pools
+	// are lava instead. Features are never placed on stairs, and never cut off any part of the map from the rest,
+	// without having to wade through something dangerous.
+	floor := m.tilesOfType(TileFloor)
+	if len(floor) == 0 {
+		return
+	}
+
+	area := len(floor)
+
+	for i := 0; i < area/400; i++ {
+		if depth >= 4 && rand.Intn(100) < 10+depth*3 {
+			m.addPool(floor, 6+rand.Intn(10), TileLava, TileLava)
+		} else {
+			m.addPool(floor, 10+rand.Intn(25), TileDeepWater, TileShallowWater)
+		}
+	}
+
+	for i := 0; i < area/300; i++ {
+		m.addPool(floor, 10+rand.Intn(30), TileTallGrass, TileGrass)
+	}
+
+	for i := 0; i < area/250; i++ {
+		m.addPool(floor, 2+rand.Intn(5), TileRubble, TileRubble)
+	}
+
+	for i := 0; i < area/500; i++ {
+		m.addPool(floor, 3+rand.Intn(6), TileFungus, TileFungus)
+	}
+}
+
+func (m *Map) addPool(floor []*Tile, size int, inner, outer int) {
+	// Grow a blob of tiles out from a random floor tile. Tiles in the middle of the blob become the inner type, and
+	// tiles along its edge become the outer type. If that would cut the map in two, the blob is taken back out again.
+	start := floor[rand.Intn(len(floor))]
+	if start.Type != TileFloor || start.Stairs != NoStairs {
+		return
+	}
+
+	blob := map[*Tile]bool{start: true}
+	edge := []*Tile{start}
+
+	for len(blob) < size && len(edge) > 0 {
+		i := rand.Intn(len(edge))
+		tile := edge[i]
+
+		grew := false
+		for _, neighbor := range m.orthogonalNeighbors(tile) {
+			if !blob[neighbor] && neighbor.Type == TileFloor && neighbor.Stairs == NoStairs && rand.Intn(2) == 0 {
+				blob[neighbor] = true
+				edge = append(edge, neighbor)
+				grew = true
+				break
+			}
+		}
+
+		if !grew {
+			edge = append(edge[:i], edge[i+1:]...)
+		}
+	}
+
+	for tile := range blob {
+		surrounded := true
+		for _, neighbor := range m.orthogonalNeighbors(tile) {
+			if !blob[neighbor] {
+				surrounded = false
+			}
+		}
+
+		if surrounded {
+			tile.SetType(inner)
+		} else {
+			tile.SetType(outer)
+		}
+	}
+
+	if !m.safelyConnected() {
+		for tile := range blob {
+			tile.SetType(TileFloor)
+		}
+	}
+}
+
+func (m *Map) orthogonalNeighbors(tile *Tile) []*Tile {
+	var neighbors []*Tile
+
+	for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		x, y := tile.X+d[0], tile.Y+d[1]
+		if x >= 0 && y >= 0 && x < m.Width && y < m.Height {
+			neighbors = append(neighbors, m.Tiles[x][y])
+		}
+	}
+
+	return neighbors
+}
+
+func (m *Map) adjacentNeighbors(tile *Tile) []*Tile {
+	// Return all eight tiles around the given one, diagonals included, the same way things move around the map
+	var neighbors []*Tile
+
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			x, y := tile.X+dx, tile.Y+dy
+			if (dx != 0 || dy != 0) && x >= 0 && y >= 0 && x < m.Width && y < m.Height {
+				neighbors = append(neighbors, m.Tiles[x][y])
+			}
+		}
+	}
+
+	return neighbors
+}
+
+func (m *Map) tilesOfType(tileType int) []*Tile {
+	var tiles []*Tile
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[x][y].Type == tileType {
+				tiles = append(tiles, m.Tiles[x][y])
+			}
+		}
+	}
+
+	return tiles
+}
+
+func (m *Map) safelyConnected() bool {
+	// Check that every tile that is not a wall, and not hazardous, can be reached from every other one, without
+	// passing through anything hazardous
+	var start *Tile
+	total := 0
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if !m.Tiles[x][y].IsWall() && !m.Tiles[x][y].IsHazardous() {
+				total++
+				if start == nil {
+					start = m.Tiles[x][y]
+				}
+			}
+		}
+	}
+
+	if start == nil {
+		return true
+	}
+
+	reached := map[*Tile]bool{start: true}
+	stack := []*Tile{start}
+
+	for len(stack) > 0 {
+		tile := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, neighbor := range m.orthogonalNeighbors(tile) {
+			if !reached[neighbor] && !neighbor.IsWall() && !neighbor.IsHazardous() {
+				reached[neighbor] = true
+				stack = append(stack, neighbor)
+			}
+		}
+	}
+
+	return len(reached) == total
+}
@@ -19,28 +19,60 @@ func (s BySize) Less(i, j int) bool {
 	return len(s[i]) < len(s[j])
 }
 
+const (
+	// The least amount of light a tile needs, to be seen from outside the torch radius
+	MinLightLevel = 1
+)
+
+const (
+	NoStairs = iota
+	StairsDown
+	StairsUp
+)
+
 type Tile struct {
 	Blocked      bool
 	Blocks_sight bool
 	Visited      bool
 	Explored     bool
-	Visible      bool
+	Mapped       bool
 	X            int
 	Y            int
+	Stairs       int
+	Type         int
+	Light        int
+	LightColor   string
+
+	// What was last seen lying on the tile, if anything, so it can still be drawn once the tile is out of view.
+	// MemoryDetected is set when it was only sensed by magic, and has not actually been seen there yet.
+	MemoryCharacter string
+	MemoryColor     string
+	MemoryDetected  bool
 }
 
 func (t *Tile) IsWall() bool {
-	if t.Blocks_sight && t.Blocked {
-		return true
-	} else {
-		return false
-	}
+	// Walls are solid rock. Other tiles may block movement or sight (a closed door, for example), but are not walls.
+	return TileTypes[t.Type].Wall
 }
 
 type Map struct {
-	Width  int
-	Height int
-	Tiles  [][]*Tile
+	Width         int
+	Height        int
+	Tiles         [][]*Tile
+	Seed          int64
+	Revision      int
+	Visibility    *Visibility
+	LightRevision int
+	changedAt     map[*Tile]int
+
+	// What the map was last lit by: the sources given, the tiles that glow by themselves, and the two together
+	sources       []LightSource
+	glowing       []LightSource
+	lights        []LightSource
+	spareLights   []LightSource
+	dirtyAreas    []Rect
+	litAt         int
+	lightingValid bool
 }
 
 func (m *Map) InitializeMap() {
@@ -50,8 +82,73 @@ func (m *Map) InitializeMap() {
 		m.Tiles[i] = make([]*Tile, m.Height)
 	}
 
-	// Set a seed for procedural generation
-	rand.Seed(time.Now().UTC().UnixNano())
+	m.Visibility = NewVisibility(m.Width, m.Height)
+
+	// Set a seed for procedural generation. A fixed seed can be given, so the same map is generated every time (useful
+	// for testing and debugging generators), otherwise the current time is used.
+	if m.Seed != 0 {
+		rand.Seed(m.Seed)
+	} else {
+		rand.Seed(time.Now().UTC().UnixNano())
+	}
+}
+
+func (m *Map) ChangeTile(x, y, tileType int) {
+	// Change the type of a tile during play (opening a door, digging through a wall, and so on). Every change bumps the
+	// map's revision, and is recorded, so anything that has cached what the map looked like (field of view, paths) can
+	// tell whether the tiles it relied on have changed since.
+	m.Tiles[x][y].SetType(tileType)
+	m.Revision++
+
+	if m.changedAt == nil {
+		m.changedAt = make(map[*Tile]int)
+	}
+	m.changedAt[m.Tiles[x][y]] = m.Revision
+}
+
+func (m *Map) ChangedSince(revision int) []*Tile {
+	// Return every tile that has been changed after the given revision of the map
+	var changed []*Tile
+
+	if revision >= m.Revision {
+		return changed
+	}
+
+	for tile, changedAt := range m.changedAt {
+		if changedAt > revision {
+			changed = append(changed, tile)
+		}
+	}
+
+	return changed
+}
+
+func (t *Tile) Remember(character, color string) {
+	t.MemoryCharacter = character
+	t.MemoryColor = color
+	t.MemoryDetected = false
+}
+
+func (t *Tile) Detect(character, color string) {
+	// Remember something that was sensed on the tile, rather than seen there. Anything already seen on the tile is
+	// left as it is.
+	if t.MemoryCharacter != "" && !t.MemoryDetected {
+		return
+	}
+	t.MemoryCharacter = character
+	t.MemoryColor = color
+	t.MemoryDetected = true
+}
+
+func (t *Tile) Forget() {
+	t.MemoryCharacter = ""
+	t.MemoryColor = ""
+	t.MemoryDetected = false
+}
+
+func (t *Tile) IsLit() bool {
+	// Lit tiles can be seen from anywhere there is a clear line of sight to them, not just within the torch radius
+	return t.Light >= MinLightLevel
 }
 
 func (m *Map) IsBlocked(x, y int) bool {
@@ -65,17 +162,46 @@ func (m *Map) IsBlocked(x, y int) bool {
 
 func (m *Map) IsVisibleToPlayer(x, y int) bool {
 	// Check to see if the given position on the map is visible to the player currently
-	if m.Tiles[x][y].Visible {
-		return true
-	} else {
-		return false
-	}
+	return m.Visibility.IsVisible(x, y)
 }
 
 func (m *Map) IsVisibleAndExplored(x, y int) bool {
-	if m.Tiles[x][y].Visible && m.Tiles[x][y].Explored {
+	if m.Visibility.IsVisible(x, y) && m.Tiles[x][y].Explored {
 		return true
 	} else {
 		return false
 	}
 }
+
+func (m *Map) PlaceStairs(x, y, direction int) {
+	// Place a staircase (StairsDown or StairsUp) on the given tile. Stairs are always placed on open floor.
+	m.Tiles[x][y].SetType(TileFloor)
+	m.Tiles[x][y].Stairs = direction
+}
+
+func (m *Map) FindStairs(direction int) (int, int, bool) {
+	// Return the location of the first staircase found going in the given direction, if there is one
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if m.Tiles[x][y].Stairs == direction {
+				return x, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func (m *Map) FloorTiles() []*Tile {
+	// Return every tile on the map that is not a wall
+	var floors []*Tile
+
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if !m.Tiles[x][y].IsWall() {
+				floors = append(floors, m.Tiles[x][y])
+			}
+		}
+	}
+
+	return floors
+}
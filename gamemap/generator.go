@@ -0,0 +1,116 @@
+package gamemap
+
+// A Generator lays out the tiles of a map. It returns every floor tile the player is able to reach, so callers know
+// where it is safe to place things.
+type Generator interface {
+	Generate(m *Map) []*Tile
+}
+
+// A RoomGenerator is a Generator that builds its map out of rooms. After generating, the rooms and their connectivity
+// graph are available, for placing things room by room.
+type RoomGenerator interface {
+	Generator
+	Rooms() ([]Rect, [][]int)
+}
+
+type CavernGenerator struct {
+	// If set, smaller caverns are joined to the main one by tunnels, rather than being filled in
+	ConnectCaverns  bool
+	MinCavernSize   int
+	SmoothingPasses int
+}
+
+func (g *CavernGenerator) Generate(m *Map) []*Tile {
+	if g.ConnectCaverns {
+		return m.GenerateConnectedCavern(g.MinCavernSize, g.SmoothingPasses)
+	}
+	return m.GenerateCavern()
+}
+
+type ArenaGenerator struct {
+}
+
+func (g *ArenaGenerator) Generate(m *Map) []*Tile {
+	m.GenerateArena()
+	return m.FloorTiles()
+}
+
+type DrunkardGenerator struct {
+	Coverage float64
+}
+
+func (g *DrunkardGenerator) Generate(m *Map) []*Tile {
+	return m.GenerateDrunkardsWalk(g.Coverage)
+}
+
+type RoomsAndCorridorsGenerator struct {
+	MaxRooms int
+	MinSize  int
+	MaxSize  int
+	rooms    []Rect
+	graph    [][]int
+}
+
+func (g *RoomsAndCorridorsGenerator) Generate(m *Map) []*Tile {
+	// Badly sized rooms leave the map as solid rock, with no floor, which callers already have to be ready for
+	var err error
+	g.rooms, g.graph, err = m.GenerateRoomsAndCorridors(g.MaxRooms, g.MinSize, g.MaxSize)
+	if err != nil {
+		return nil
+	}
+	return m.FloorTiles()
+}
+
+func (g *RoomsAndCorridorsGenerator) Rooms() ([]Rect, [][]int) {
+	return g.rooms, g.graph
+}
+
+type BSPGenerator struct {
+	MinLeafSize   int
+	RoomFillRatio float64
+	CorridorStyle int
+	rooms         []Rect
+	graph         [][]int
+}
+
+func (g *BSPGenerator) Generate(m *Map) []*Tile {
+	g.rooms, g.graph = m.GenerateBSP(g.MinLeafSize, g.RoomFillRatio, g.CorridorStyle)
+	return m.FloorTiles()
+}
+
+func (g *BSPGenerator) Rooms() ([]Rect, [][]int) {
+	return g.rooms, g.graph
+}
+
+// Every generator that can be picked by name, each with its default settings
+var generators = map[string]func() Generator{
+	"cavern": func() Generator { return &CavernGenerator{} },
+	"connected_cavern": func() Generator {
+		return &CavernGenerator{ConnectCaverns: true, MinCavernSize: 20, SmoothingPasses: 2}
+	},
+	"drunkard": func() Generator { return &DrunkardGenerator{Coverage: 0.4} },
+	"mixed": func() Generator {
+		// Caverns on the left half of the map, and carved out rooms on the right
+		return &RegionalGenerator{Regions: []Region{
+			{Left: 0, Top: 0, Right: 0.5, Bottom: 1, Generator: &CavernGenerator{}},
+			{Left: 0.5, Top: 0, Right: 1, Bottom: 1, Generator: &RoomsAndCorridorsGenerator{MaxRooms: 20, MinSize: 4, MaxSize: 10}},
+		}}
+	},
+	"arena": func() Generator { return &ArenaGenerator{} },
+	"rooms": func() Generator { return &RoomsAndCorridorsGenerator{MaxRooms: 40, MinSize: 5, MaxSize: 12} },
+	"bsp": func() Generator {
+		return &BSPGenerator{MinLeafSize: 12, RoomFillRatio: 0.7, CorridorStyle: CorridorLShaped}
+	},
+	"bsp_winding": func() Generator {
+		return &BSPGenerator{MinLeafSize: 10, RoomFillRatio: 0.6, CorridorStyle: CorridorWinding}
+	},
+}
+
+func GeneratorByName(name string) (Generator, bool) {
+	// Return a new generator, with its default settings, by name. Returns false if there is no generator by that name.
+	newGenerator, ok := generators[name]
+	if !ok {
+		return nil, false
+	}
+	return newGenerator(), true
+}
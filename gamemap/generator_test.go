@@ -0,0 +1,41 @@
+package gamemap
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGeneratorsByName(t *testing.T) {
+	var names []string
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			m := &Map{Width: 100, Height: 100, Seed: 7}
+			m.InitializeMap()
+
+			generator, ok := GeneratorByName(name)
+			if !ok || generator == nil {
+				t.Fatalf("no generator called %q", name)
+			}
+
+			floor := generator.Generate(m)
+			if len(floor) == 0 {
+				t.Fatal("the map has no floor")
+			}
+
+			if caverns := m.findCaverns(); len(caverns) != 1 {
+				t.Errorf("the map is split into %d separate areas", len(caverns))
+			}
+		})
+	}
+}
+
+func TestUnknownGenerator(t *testing.T) {
+	if generator, ok := GeneratorByName("no such generator"); ok || generator != nil {
+		t.Error("expected no generator for an unknown name")
+	}
+}
@@ -0,0 +1,196 @@
+package gamemap
+
+import "math"
+
+// A LightSource is anything that lights up the map around it
+type LightSource struct {
+	X      int
+	Y      int
+	Radius int
+	Color  string
+}
+
+func (l LightSource) reaches(r Rect) bool {
+	// Check whether anything inside the given area could be within reach of the light
+	return l.X+l.Radius >= r.X1 && l.X-l.Radius <= r.X2 && l.Y+l.Radius >= r.Y1 && l.Y-l.Radius <= r.Y2
+}
+
+func (l LightSource) area() Rect {
+	return Rect{X1: l.X - l.Radius, Y1: l.Y - l.Radius, X2: l.X + l.Radius, Y2: l.Y + l.Radius}
+}
+
+func (m *Map) UpdateLighting(sources []LightSource, cast func(x, y, radius int) []*Tile) {
+	// Work out how brightly lit every tile on the map is. Light comes from glowing tiles (lava, fungus), and from the
+	// given sources. The cast function returns every tile a source at the given location can light, out to its radius.
+	// Light fades with distance, and where the light from several sources overlaps, the brightest one wins, and decides
+	// the color of the tile.
+	//
+	// The first time round, the whole map is lit. After that, only the areas around lights that have appeared,
+	// disappeared or moved, or that had a tile change within their reach, are lit again, and only the lights that reach
+	// into those areas are cast again. Whenever anything is lit again, LightRevision goes up by one.
+	if !m.lightingValid {
+		m.relightAll(sources, cast)
+		return
+	}
+
+	changed := m.ChangedSince(m.litAt)
+	if len(changed) == 0 && sameLightSources(m.sources, sources) {
+		return
+	}
+
+	m.updateGlowingTiles(changed)
+
+	previous := m.lights
+	m.lights = append(append(m.spareLights[:0], m.glowing...), sources...)
+	m.spareLights = previous
+
+	// Work out which parts of the map need lighting again. Anywhere a light used to reach, or now reaches, that is not
+	// lit exactly the same way as before, and all around any light that a changed tile could now block, or let through.
+	dirty := m.dirtyAreas[:0]
+
+	for _, light := range lightDifference(previous, m.lights) {
+		dirty = append(dirty, light.area())
+	}
+	for _, light := range lightDifference(m.lights, previous) {
+		dirty = append(dirty, light.area())
+	}
+	for _, tile := range changed {
+		for _, light := range m.lights {
+			if light.reaches(Rect{X1: tile.X, Y1: tile.Y, X2: tile.X, Y2: tile.Y}) {
+				dirty = append(dirty, light.area())
+			}
+		}
+	}
+
+	m.dirtyAreas = dirty
+	m.sources = append(m.sources[:0], sources...)
+	m.litAt = m.Revision
+
+	if len(dirty) == 0 {
+		return
+	}
+
+	for _, area := range dirty {
+		m.clearLight(area)
+	}
+
+	// Any light reaching into a cleared area is cast again. Outside the cleared areas, this leaves tiles as they were,
+	// since the brightest light there is already the one they have.
+	for _, light := range m.lights {
+		for _, area := range dirty {
+			if light.reaches(area) {
+				m.castLight(light, cast)
+				break
+			}
+		}
+	}
+
+	m.LightRevision++
+}
+
+func (m *Map) relightAll(sources []LightSource, cast func(x, y, radius int) []*Tile) {
+	// Light the whole map from scratch
+	m.clearLight(Rect{X1: 0, Y1: 0, X2: m.Width - 1, Y2: m.Height - 1})
+
+	m.glowing = m.glowing[:0]
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			if light, ok := m.glowingTile(m.Tiles[x][y]); ok {
+				m.glowing = append(m.glowing, light)
+			}
+		}
+	}
+
+	m.lights = append(append(m.lights[:0], m.glowing...), sources...)
+	for _, light := range m.lights {
+		m.castLight(light, cast)
+	}
+
+	m.sources = append(m.sources[:0], sources...)
+	m.litAt = m.Revision
+	m.lightingValid = true
+	m.LightRevision++
+}
+
+func (m *Map) updateGlowingTiles(changed []*Tile) {
+	// Bring the list of glowing tiles up to date, after the given tiles have changed type
+	for _, tile := range changed {
+		for i := 0; i < len(m.glowing); i++ {
+			if m.glowing[i].X == tile.X && m.glowing[i].Y == tile.Y {
+				m.glowing = append(m.glowing[:i], m.glowing[i+1:]...)
+				i--
+			}
+		}
+
+		if light, ok := m.glowingTile(tile); ok {
+			m.glowing = append(m.glowing, light)
+		}
+	}
+}
+
+func (m *Map) glowingTile(tile *Tile) (LightSource, bool) {
+	tileType := tile.TileType()
+	if tileType.LightRadius == 0 {
+		return LightSource{}, false
+	}
+	return LightSource{X: tile.X, Y: tile.Y, Radius: tileType.LightRadius, Color: tileType.LightColor}, true
+}
+
+func (m *Map) clearLight(area Rect) {
+	for x := area.X1; x <= area.X2; x++ {
+		for y := area.Y1; y <= area.Y2; y++ {
+			if x >= 0 && y >= 0 && x < m.Width && y < m.Height {
+				m.Tiles[x][y].Light = 0
+				m.Tiles[x][y].LightColor = ""
+			}
+		}
+	}
+}
+
+func (m *Map) castLight(light LightSource, cast func(x, y, radius int) []*Tile) {
+	// Light up every tile the source can see, out to its radius. Tiles are brightest next to the source, and get one
+	// level dimmer for each step away from it. Where two lights are equally bright, the color is picked by name, so a
+	// tile ends up the same whatever order the lights were cast in.
+	for _, tile := range cast(light.X, light.Y, light.Radius) {
+		dx, dy := float64(tile.X-light.X), float64(tile.Y-light.Y)
+		level := light.Radius + 1 - int(round(math.Sqrt(dx*dx+dy*dy)))
+
+		if level > tile.Light || level == tile.Light && level > 0 && light.Color < tile.LightColor {
+			tile.Light = level
+			tile.LightColor = light.Color
+		}
+	}
+}
+
+func lightDifference(a, b []LightSource) []LightSource {
+	// Return every light in a that is not also in b. Lights that appear more than once are matched up one for one.
+	var difference []LightSource
+
+	remaining := make(map[LightSource]int, len(b))
+	for _, light := range b {
+		remaining[light]++
+	}
+
+	for _, light := range a {
+		if remaining[light] > 0 {
+			remaining[light]--
+		} else {
+			difference = append(difference, light)
+		}
+	}
+
+	return difference
+}
+
+func sameLightSources(a, b []LightSource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
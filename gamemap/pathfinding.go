@@ -0,0 +1,162 @@
+package gamemap
+
+import (
+	"container/heap"
+)
+
+const (
+	// Opening a closed door costs a turn, on top of walking through it
+	DoorOpenCost = 1
+)
+
+type pathNode struct {
+	tile     *Tile
+	cost     int
+	estimate int
+	index    int
+}
+
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int {
+	return len(q)
+}
+
+func (q pathQueue) Less(i, j int) bool {
+	return q[i].estimate < q[j].estimate
+}
+
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *pathQueue) Push(x interface{}) {
+	node := x.(*pathNode)
+	node.index = len(*q)
+	*q = append(*q, node)
+}
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+func (m *Map) FindPath(startX, startY, goalX, goalY int, maxNodes int, canPass func(tile *Tile) bool) []*Tile {
+	// Find the cheapest path between two points using A*. Moving onto a tile costs that tile's movement cost, plus a
+	// little extra for closed doors, as they have to be opened first. canPass decides which tiles may be walked
+	// through at all (the goal is always allowed, as there is often something standing on it). The search gives up
+	// after looking at maxNodes tiles, so far off or unreachable goals do not take forever. Returns the tiles along the
+	// path, not including the start, or nil if no path was found.
+	if startX == goalX && startY == goalY {
+		return nil
+	}
+
+	start := m.Tiles[startX][startY]
+	goal := m.Tiles[goalX][goalY]
+
+	costs := map[*Tile]int{start: 0}
+	cameFrom := map[*Tile]*Tile{}
+
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{tile: start, cost: 0, estimate: distanceBetween(start, goal)})
+
+	for visited := 0; open.Len() > 0 && visited < maxNodes; visited++ {
+		current := heap.Pop(open).(*pathNode)
+
+		if current.tile == goal {
+			var path []*Tile
+			for tile := goal; tile != start; tile = cameFrom[tile] {
+				path = append([]*Tile{tile}, path...)
+			}
+			return path
+		}
+
+		// Skip stale entries, that a cheaper route to the same tile has since replaced
+		if current.cost > costs[current.tile] {
+			continue
+		}
+
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				x, y := current.tile.X+dx, current.tile.Y+dy
+
+				if (dx == 0 && dy == 0) || x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+					continue
+				}
+
+				next := m.Tiles[x][y]
+				if next != goal && !canPass(next) {
+					continue
+				}
+
+				cost := current.cost + next.TileType().MoveCost
+				if next.Type == TileDoorClosed || next.Type == TileDoorLocked {
+					cost += DoorOpenCost
+				}
+
+				if previous, seen := costs[next]; !seen || cost < previous {
+					costs[next] = cost
+					cameFrom[next] = current.tile
+					heap.Push(open, &pathNode{tile: next, cost: cost, estimate: cost + distanceBetween(next, goal)})
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Map) ReachableTiles(x, y int, canPass func(tile *Tile) bool) []*Tile {
+	// Return every tile that can be reached by walking from the given location, through tiles canPass allows. Steps
+	// can be taken in any of the eight directions, the same as FindPath, so this agrees with where things can move.
+	start := m.Tiles[x][y]
+	reached := map[*Tile]bool{start: true}
+	tiles := []*Tile{start}
+
+	for i := 0; i < len(tiles); i++ {
+		for _, neighbor := range m.adjacentNeighbors(tiles[i]) {
+			if !reached[neighbor] && canPass(neighbor) {
+				reached[neighbor] = true
+				tiles = append(tiles, neighbor)
+			}
+		}
+	}
+
+	return tiles
+}
+
+func (m *Map) StepDistances(x, y, maxSteps int, canPass func(tile *Tile) bool) map[*Tile]int {
+	// Return how many steps it takes to reach each tile within maxSteps of the given location, moving in any of the
+	// eight directions, through tiles canPass allows. Tiles that cannot be reached in that many steps are left out.
+	start := m.Tiles[x][y]
+	steps := map[*Tile]int{start: 0}
+	tiles := []*Tile{start}
+
+	for i := 0; i < len(tiles); i++ {
+		if steps[tiles[i]] >= maxSteps {
+			continue
+		}
+
+		for _, neighbor := range m.adjacentNeighbors(tiles[i]) {
+			if _, seen := steps[neighbor]; !seen && canPass(neighbor) {
+				steps[neighbor] = steps[tiles[i]] + 1
+				tiles = append(tiles, neighbor)
+			}
+		}
+	}
+
+	return steps
+}
+
+func distanceBetween(a, b *Tile) int {
+	// Diagonal moves cost the same as straight ones, so the distance is the larger of the two axes
+	dx, dy := abs(a.X-b.X), abs(a.Y-b.Y)
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
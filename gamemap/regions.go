@@ -0,0 +1,72 @@
+package gamemap
+
+// A Region is a part of the map, given as fractions (0 to 1) of the map's width and height, to be laid out by its own
+// generator
+type Region struct {
+	Left      float64
+	Top       float64
+	Right     float64
+	Bottom    float64
+	Generator Generator
+}
+
+// A RegionalGenerator lays out a map in several regions, each by a different generator, and then stitches them
+// together with tunnels, so the whole map can be reached
+type RegionalGenerator struct {
+	Regions []Region
+}
+
+func (g *RegionalGenerator) Generate(m *Map) []*Tile {
+	return m.GenerateRegions(g.Regions)
+}
+
+func (m *Map) GenerateRegions(regions []Region) []*Tile {
+	// Generate each region as a small map of its own, and copy it into place. Each one comes with a wall around its
+	// edges, so the regions are kept apart until they are tunnelled together, each one to the region before it.
+	m.fillWithWalls()
+
+	var previous []*Tile
+
+	for _, region := range regions {
+		x1, y1 := int(region.Left*float64(m.Width)), int(region.Top*float64(m.Height))
+		x2, y2 := int(region.Right*float64(m.Width)), int(region.Bottom*float64(m.Height))
+
+		area := &Map{Width: x2 - x1, Height: y2 - y1}
+		area.Tiles = make([][]*Tile, area.Width)
+		for i := range area.Tiles {
+			area.Tiles[i] = make([]*Tile, area.Height)
+		}
+
+		floors := region.Generator.Generate(area)
+
+		for x := 0; x < area.Width; x++ {
+			for y := 0; y < area.Height; y++ {
+				tile := area.Tiles[x][y]
+				tile.X, tile.Y = x1+x, y1+y
+				m.Tiles[tile.X][tile.Y] = tile
+
+				// The bedrock around the edge of each region is only the edge of the map it was generated on
+				if tile.Type == TileBedrock {
+					tile.SetType(TileWall)
+				}
+			}
+		}
+
+		if len(floors) == 0 {
+			continue
+		}
+
+		if previous != nil {
+			from, to := closestTiles(floors, previous)
+			m.digTunnel(from.X, from.Y, to.X, to.Y)
+		}
+
+		previous = append(previous, floors...)
+	}
+
+	// The outer edge of the map is always bedrock, even if a region left it open
+	m.sealEdges()
+
+	// Every region should now be connected, but fill in anything that is not, just to be sure
+	return m.keepLargestCavern(m.findCaverns())
+}
@@ -0,0 +1,299 @@
+package gamemap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+type Rect struct {
+	X1 int
+	Y1 int
+	X2 int
+	Y2 int
+}
+
+func (r Rect) Center() (int, int) {
+	return (r.X1 + r.X2) / 2, (r.Y1 + r.Y2) / 2
+}
+
+func (r Rect) Intersects(other Rect) bool {
+	// Check if two rooms overlap, or are touching. Rooms are kept at least one wall apart.
+	return r.X1 <= other.X2+1 && r.X2 >= other.X1-1 && r.Y1 <= other.Y2+1 && r.Y2 >= other.Y1-1
+}
+
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.X1 && x <= r.X2 && y >= r.Y1 && y <= r.Y2
+}
+
+func (m *Map) GenerateRoomsAndCorridors(maxRooms, minSize, maxSize int) ([]Rect, [][]int, error) {
+	// Generate a classic dungeon of rectangular rooms, joined by corridors. Returns the rooms that were created (the
+	// floor area of each, inclusive), and a connectivity graph. graph[i] lists the indices of every room that room i
+	// has a corridor to. If the room sizes make no sense, an error is returned, and the map is left as solid rock.
+
+	// Step 1: Start with solid rock
+	m.fillWithWalls()
+
+	if minSize < 1 {
+		return nil, nil, fmt.Errorf("the minimum room size must be at least 1, not %d", minSize)
+	}
+
+	if maxSize < minSize {
+		return nil, nil, fmt.Errorf("the maximum room size (%d) is smaller than the minimum (%d)", maxSize, minSize)
+	}
+
+	// Step 2: Try to place each room at random. Any room that would overlap an existing one is thrown away.
+	var rooms []Rect
+
+	for i := 0; i < maxRooms; i++ {
+		width := minSize + rand.Intn(maxSize-minSize+1)
+		height := minSize + rand.Intn(maxSize-minSize+1)
+
+		if width >= m.Width-2 || height >= m.Height-2 {
+			continue
+		}
+
+		x := 1 + rand.Intn(m.Width-width-2)
+		y := 1 + rand.Intn(m.Height-height-2)
+
+		room := Rect{X1: x, Y1: y, X2: x + width - 1, Y2: y + height - 1}
+
+		overlaps := false
+		for _, other := range rooms {
+			if room.Intersects(other) {
+				overlaps = true
+				break
+			}
+		}
+
+		if !overlaps {
+			m.carveRoom(room)
+			rooms = append(rooms, room)
+		}
+	}
+
+	graph := make([][]int, len(rooms))
+
+	// Step 3: Join each room to the one placed before it. This chains every room together, which guarantees the whole
+	// dungeon can be reached.
+	for i := 1; i < len(rooms); i++ {
+		m.connectRooms(rooms, graph, i-1, i)
+	}
+
+	// Step 4: Add a few extra corridors between rooms that are close to one another, so there are some loops, and not
+	// everything is a long chain.
+	for i := 0; i < len(rooms)/4; i++ {
+		a := rand.Intn(len(rooms))
+		b := closestRoom(rooms, a, graph[a])
+
+		if b >= 0 {
+			m.connectRooms(rooms, graph, a, b)
+		}
+	}
+
+	return rooms, graph, nil
+}
+
+func RoomDeadEnds(graph [][]int) []int {
+	// Return the index of every room that only has a single corridor leading to it
+	var deadEnds []int
+
+	for i, neighbors := range graph {
+		if len(neighbors) == 1 {
+			deadEnds = append(deadEnds, i)
+		}
+	}
+
+	return deadEnds
+}
+
+func (m *Map) fillWithWalls() {
+	// Fill the whole map with wall tiles, with bedrock around the edges
+	for x := 0; x < m.Width; x++ {
+		for y := 0; y < m.Height; y++ {
+			m.Tiles[x][y] = newTile(x, y, TileWall)
+		}
+	}
+
+	m.sealEdges()
+}
+
+func (m *Map) carveRoom(room Rect) {
+	for x := room.X1; x <= room.X2; x++ {
+		for y := room.Y1; y <= room.Y2; y++ {
+			m.carve(x, y)
+		}
+	}
+}
+
+func (m *Map) carve(x, y int) {
+	// Turn a single tile into floor. The outer edge of the map is never carved.
+	if x <= 0 || y <= 0 || x >= m.Width-1 || y >= m.Height-1 {
+		return
+	}
+
+	m.Tiles[x][y].SetType(TileFloor)
+}
+
+func (m *Map) connectRooms(rooms []Rect, graph [][]int, a, b int) {
+	// Dig an L-shaped corridor between the centers of two rooms, and record the connection in the graph
+	x1, y1 := rooms[a].Center()
+	x2, y2 := rooms[b].Center()
+
+	m.digCorridor(x1, y1, x2, y2, CorridorLShaped)
+
+	graph[a] = append(graph[a], b)
+	graph[b] = append(graph[b], a)
+}
+
+func (m *Map) digCorridor(x1, y1, x2, y2, style int) {
+	// Dig a corridor between two points, in the given style
+	switch style {
+	case CorridorStraight:
+		m.carveStraight(x1, y1, x2, y2)
+	case CorridorWinding:
+		m.carveWinding(x1, y1, x2, y2)
+	default:
+		if rand.Intn(2) == 0 {
+			m.carveHorizontal(x1, x2, y1)
+			m.carveVertical(y1, y2, x2)
+		} else {
+			m.carveVertical(y1, y2, x1)
+			m.carveHorizontal(x1, x2, y2)
+		}
+	}
+}
+
+func (m *Map) carveStraight(x1, y1, x2, y2 int) {
+	// Carve as direct a line as possible between two points. Whenever the line steps diagonally, the tile beside it is
+	// carved out too, so the corridor can always be walked without squeezing between two walls.
+	dx, dy := x2-x1, y2-y1
+	steps := abs(dx)
+	if abs(dy) > steps {
+		steps = abs(dy)
+	}
+
+	lastX, lastY := x1, y1
+	m.carve(x1, y1)
+
+	for i := 1; i <= steps; i++ {
+		x := x1 + int(round(float64(dx*i)/float64(steps)))
+		y := y1 + int(round(float64(dy*i)/float64(steps)))
+
+		if x != lastX && y != lastY {
+			m.carve(x, lastY)
+		}
+
+		m.carve(x, y)
+		lastX, lastY = x, y
+	}
+}
+
+func (m *Map) carveWinding(x1, y1, x2, y2 int) {
+	// Carve a wandering tunnel between two points. Each step usually heads towards the destination, but now and then
+	// it drifts off to one side, which gives a more natural, twisting passage.
+	x, y := x1, y1
+	m.carve(x, y)
+
+	for x != x2 || y != y2 {
+		stepX, stepY := sign(x2-x), sign(y2-y)
+
+		if rand.Intn(100) < 30 {
+			// Drift sideways, relative to the main direction of travel
+			if abs(x2-x) > abs(y2-y) {
+				stepX, stepY = 0, rand.Intn(3)-1
+			} else {
+				stepX, stepY = rand.Intn(3)-1, 0
+			}
+		} else if stepX != 0 && stepY != 0 {
+			// Only move along one axis at a time, so the tunnel stays connected
+			if rand.Intn(2) == 0 {
+				stepX = 0
+			} else {
+				stepY = 0
+			}
+		}
+
+		if x+stepX > 0 && x+stepX < m.Width-1 {
+			x += stepX
+		}
+		if y+stepY > 0 && y+stepY < m.Height-1 {
+			y += stepY
+		}
+
+		m.carve(x, y)
+	}
+}
+
+func (m *Map) carveHorizontal(x1, x2, y int) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+
+	for x := x1; x <= x2; x++ {
+		m.carve(x, y)
+	}
+}
+
+func (m *Map) carveVertical(y1, y2, x int) {
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+
+	for y := y1; y <= y2; y++ {
+		m.carve(x, y)
+	}
+}
+
+func closestRoom(rooms []Rect, index int, exclude []int) int {
+	// Find the room closest to the given one, that it is not already connected to. Returns -1 if there is none.
+	closest := -1
+	closestDistance := 0
+
+	x, y := rooms[index].Center()
+
+	for i, room := range rooms {
+		if i == index || containsInt(exclude, i) {
+			continue
+		}
+
+		otherX, otherY := room.Center()
+		distance := (otherX-x)*(otherX-x) + (otherY-y)*(otherY-y)
+
+		if closest < 0 || distance < closestDistance {
+			closest = i
+			closestDistance = distance
+		}
+	}
+
+	return closest
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sign(n int) int {
+	if n < 0 {
+		return -1
+	} else if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+func round(f float64) float64 {
+	return math.Floor(f + .5)
+}
@@ -0,0 +1,99 @@
+package gamemap
+
+import "testing"
+
+func generateRooms(t *testing.T, seed int64) (*Map, []Rect, [][]int) {
+	m := &Map{Width: 100, Height: 100, Seed: seed}
+	m.InitializeMap()
+	rooms, graph, err := m.GenerateRoomsAndCorridors(40, 5, 12)
+	if err != nil {
+		t.Fatalf("seed %d: %v", seed, err)
+	}
+	return m, rooms, graph
+}
+
+func TestRoomsAndCorridorsAreConnected(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		m, rooms, graph := generateRooms(t, seed)
+
+		if len(rooms) == 0 {
+			t.Fatalf("seed %d: no rooms were generated", seed)
+		}
+
+		if caverns := m.findCaverns(); len(caverns) != 1 {
+			t.Errorf("seed %d: the map is split into %d separate areas", seed, len(caverns))
+		}
+
+		// Every room should be reachable from the first, by following the corridors in the graph
+		reached := map[int]bool{0: true}
+		stack := []int{0}
+
+		for len(stack) > 0 {
+			room := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			for _, neighbor := range graph[room] {
+				if !reached[neighbor] {
+					reached[neighbor] = true
+					stack = append(stack, neighbor)
+				}
+			}
+		}
+
+		if len(reached) != len(rooms) {
+			t.Errorf("seed %d: only %d of %d rooms are connected in the graph", seed, len(reached), len(rooms))
+		}
+	}
+}
+
+func TestRoomsAndCorridorsFixedSeed(t *testing.T) {
+	// The same seed should always give exactly the same map
+	first, firstRooms, _ := generateRooms(t, 42)
+	second, secondRooms, _ := generateRooms(t, 42)
+
+	if len(firstRooms) != len(secondRooms) {
+		t.Fatalf("got %d rooms the first time, and %d the second", len(firstRooms), len(secondRooms))
+	}
+
+	for i := range firstRooms {
+		if firstRooms[i] != secondRooms[i] {
+			t.Errorf("room %d differs: %v and %v", i, firstRooms[i], secondRooms[i])
+		}
+	}
+
+	for x := 0; x < first.Width; x++ {
+		for y := 0; y < first.Height; y++ {
+			if first.Tiles[x][y].Type != second.Tiles[x][y].Type {
+				t.Fatalf("tile (%d, %d) differs between the two maps", x, y)
+			}
+		}
+	}
+}
+
+func TestRoomsAndCorridorsBadSizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		minSize int
+		maxSize int
+	}{
+		{"minimum larger than maximum", 12, 5},
+		{"no minimum", 0, 5},
+		{"negative sizes", -3, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := &Map{Width: 40, Height: 40, Seed: 1}
+			m.InitializeMap()
+
+			if _, _, err := m.GenerateRoomsAndCorridors(10, test.minSize, test.maxSize); err == nil {
+				t.Error("expected an error")
+			}
+
+			generator := &RoomsAndCorridorsGenerator{MaxRooms: 10, MinSize: test.minSize, MaxSize: test.maxSize}
+			if floor := generator.Generate(m); len(floor) != 0 {
+				t.Errorf("expected no floor, got %d tiles", len(floor))
+			}
+		})
+	}
+}
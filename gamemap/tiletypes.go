@@ -0,0 +1,123 @@
+package gamemap
+
+import (
+	"strings"
+)
+
+const (
+	// Tile types. A tile's type decides how it looks, and how it behaves, see TileTypes.
+	TileWall = iota
+	TileFloor
+	TileRubble
+	TileGrass
+	TileTallGrass
+	TileShallowWater
+	TileDeepWater
+	TileLava
+	TileDoorClosed
+	TileDoorOpen
+	TileDoorLocked
+	TileBedrock
+	TileFungus
+)
+
+const (
+	// What happens to anything that steps onto a tile
+	EnterNothing = iota
+	EnterBurn
+	EnterSwim
+	EnterTrample
+)
+
+// A TileType describes one kind of tile: how it is drawn (when it is in view, and when it is only remembered), whether
+// it can be walked through or seen through, how many turns it takes to cross, what happens to anything that steps
+// onto it, how many turns it takes to dig through (zero if it cannot be dug), and how far it lights up the area around
+// it (zero if it gives off no light)
+type TileType struct {
+	Name            string
+	Description     string
+	Character       string
+	LitColor        string
+	RememberedColor string
+	Blocked         bool
+	BlocksSight     bool
+	Wall            bool
+	MoveCost        int
+	OnEnter         int
+	DigTurns        int
+	LightRadius     int
+	LightColor      string
+}
+
+var TileTypes = map[int]TileType{
+	TileWall: {Name: "wall", Description: "A cavern wall, made of some kind of rock", Character: "#",
+		LitColor: "white", RememberedColor: "gray", Blocked: true, BlocksSight: true, Wall: true, MoveCost: 1, DigTurns: 5},
+	TileFloor: {Name: "floor", Description: "A cavern floor, covered in dirt and stones", Character: ".",
+		LitColor: "white", RememberedColor: "gray", MoveCost: 1},
+	TileRubble: {Name: "rubble", Description: "A heap of loose rubble, slow going underfoot", Character: ":",
+		LitColor: "light gray", RememberedColor: "dark gray", MoveCost: 2},
+	TileGrass: {Name: "grass", Description: "A patch of pale, trampled grass", Character: "\"",
+		LitColor: "light green", RememberedColor: "darker green", MoveCost: 1},
+	TileTallGrass: {Name: "tall grass", Description: "Tall, thick grass. Anything could be hiding in it.", Character: "\"",
+		LitColor: "green", RememberedColor: "darker green", BlocksSight: true, MoveCost: 1, OnEnter: EnterTrample},
+	TileShallowWater: {Name: "shallow water", Description: "Shallow, muddy water, that drags at your feet", Character: "~",
+		LitColor: "light blue", RememberedColor: "darker blue", MoveCost: 2},
+	TileDeepWater: {Name: "deep water", Description: "Deep, dark water. Anything carried through it may be lost.", Character: "~",
+		LitColor: "blue", RememberedColor: "darker blue", MoveCost: 2, OnEnter: EnterSwim},
+	TileLava: {Name: "lava", Description: "A pool of glowing, molten rock", Character: "~",
+		LitColor: "orange", RememberedColor: "dark red", MoveCost: 1, OnEnter: EnterBurn, LightRadius: 3, LightColor: "orange"},
+	TileDoorClosed: {Name: "closed door", Description: "A heavy wooden door, shut tight", Character: "+",
+		LitColor: "light orange", RememberedColor: "dark orange", Blocked: true, BlocksSight: true, MoveCost: 1},
+	TileDoorOpen: {Name: "open door", Description: "A heavy wooden door, standing open", Character: "'",
+		LitColor: "light orange", RememberedColor: "dark orange", MoveCost: 1},
+	TileDoorLocked: {Name: "locked door", Description: "A heavy wooden door, locked tight. There must be a key somewhere.",
+		Character: "+", LitColor: "light orange", RememberedColor: "dark orange", Blocked: true, BlocksSight: true, MoveCost: 1},
+	TileBedrock: {Name: "bedrock", Description: "Solid bedrock, at the very edge of the caverns. Nothing can dig through it.",
+		Character: "#", LitColor: "lighter gray", RememberedColor: "darker gray", Blocked: true, BlocksSight: true, Wall: true,
+		MoveCost: 1},
+	TileFungus: {Name: "glowing fungus", Description: "A carpet of soft fungus, giving off a faint, cold glow", Character: ",",
+		LitColor: "lighter cyan", RememberedColor: "darker cyan", MoveCost: 1, LightRadius: 2, LightColor: "cyan"},
+}
+
+func TileTypeByName(name string) (int, bool) {
+	// Look up a tile type by its name. Underscores may be used in place of spaces (deep_water), for use in data files.
+	name = strings.Replace(name, "_", " ", -1)
+
+	for tileType, t := range TileTypes {
+		if t.Name == name {
+			return tileType, true
+		}
+	}
+	return 0, false
+}
+
+func newTile(x, y, tileType int) *Tile {
+	tile := &Tile{X: x, Y: y, Stairs: NoStairs}
+	tile.SetType(tileType)
+	return tile
+}
+
+func (t *Tile) SetType(tileType int) {
+	// Change what kind of tile this is. Whether it can be walked or seen through comes along with the type.
+	t.Type = tileType
+	t.Blocked = TileTypes[tileType].Blocked
+	t.Blocks_sight = TileTypes[tileType].BlocksSight
+}
+
+func (t *Tile) TileType() TileType {
+	return TileTypes[t.Type]
+}
+
+func (t *Tile) IsDiggable() bool {
+	return TileTypes[t.Type].DigTurns > 0
+}
+
+func (t *Tile) IsDoor() bool {
+	return t.Type == TileDoorClosed || t.Type == TileDoorOpen || t.Type == TileDoorLocked
+}
+
+func (t *Tile) IsHazardous() bool {
+	// Hazardous tiles are ones that anything with sense would avoid walking into
+	onEnter := TileTypes[t.Type].OnEnter
+	return onEnter == EnterBurn || onEnter == EnterSwim
+}
@@ -0,0 +1,331 @@
+package gamemap
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A Vault is a hand designed room, drawn as ASCII art, that can be stamped into a generated map. '#' is a wall, '.' is
+// floor, and a space leaves whatever the generator put there alone. Any other character is looked up in the legend,
+// which either names a type of tile (such as deep_water), or something to spawn on a floor tile.
+type Vault struct {
+	Name     string
+	Weight   int
+	MinDepth int
+	MaxDepth int
+	Legend   map[rune]string
+	Rows     []string
+}
+
+// A VaultSpawn is something a vault wants placed on the map, by the name given in its legend
+type VaultSpawn struct {
+	X    int
+	Y    int
+	Name string
+}
+
+// VaultSpawnNames are the things a vault legend can ask to have spawned, besides types of tile. The game decides what
+// each one actually turns into.
+var VaultSpawnNames = map[string]bool{
+	"monster":  true,
+	"guardian": true,
+	"item":     true,
+	"brazier":  true,
+}
+
+func LoadVaults(directory string) ([]*Vault, error) {
+	// Load every vault file (*.vault) in the given directory
+	paths, err := filepath.Glob(filepath.Join(directory, "*.vault"))
+	if err != nil {
+		return nil, err
+	}
+
+	var vaults []*Vault
+
+	for _, path := range paths {
+		vault, err := LoadVault(path)
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, vault)
+	}
+
+	return vaults, nil
+}
+
+func LoadVault(path string) (*Vault, error) {
+	// Load a single vault from a file. The file starts with a few "key: value" settings (name, weight, depth, and
+	// any number of legend entries), followed by a "map:" line, with the vault itself drawn on the lines after it.
+	// Lines starting with ';' are comments.
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vault := &Vault{Name: filepath.Base(path), Weight: 1, MinDepth: 1, MaxDepth: 0, Legend: map[rune]string{}}
+	readingMap := false
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if readingMap {
+			vault.Rows = append(vault.Rows, line)
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, lineNumber)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		switch key {
+		case "name":
+			vault.Name = value
+		case "weight":
+			vault.Weight, err = strconv.Atoi(value)
+		case "depth":
+			vault.MinDepth, vault.MaxDepth, err = parseDepthRange(value)
+		case "legend":
+			// A legend entry is a single character, followed by the name of what to spawn there
+			fields := strings.Fields(value)
+			if len(fields) != 2 || len([]rune(fields[0])) != 1 {
+				return nil, fmt.Errorf("%s:%d: expected \"legend: <character> <name>\"", path, lineNumber)
+			}
+			if _, ok := TileTypeByName(fields[1]); !ok && !VaultSpawnNames[fields[1]] {
+				return nil, fmt.Errorf("%s:%d: %q is not a type of tile, or anything that can be spawned", path, lineNumber, fields[1])
+			}
+			vault.Legend[[]rune(fields[0])[0]] = fields[1]
+		case "map":
+			readingMap = true
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q", path, lineNumber, key)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Trailing blank lines are not part of the vault, and every row is padded out to the same width
+	for len(vault.Rows) > 0 && strings.TrimSpace(vault.Rows[len(vault.Rows)-1]) == "" {
+		vault.Rows = vault.Rows[:len(vault.Rows)-1]
+	}
+
+	if len(vault.Rows) == 0 {
+		return nil, fmt.Errorf("%s: vault has no map", path)
+	}
+
+	// Every character in the map has to mean something, so a typo does not quietly turn into floor
+	for i, row := range vault.Rows {
+		for _, glyph := range row {
+			if _, ok := vault.Legend[glyph]; !ok && glyph != '#' && glyph != '.' && glyph != ' ' {
+				return nil, fmt.Errorf("%s: map row %d: %q is not in the legend", path, i+1, glyph)
+			}
+		}
+	}
+
+	width := vault.Width()
+	for i, row := range vault.Rows {
+		vault.Rows[i] = row + strings.Repeat(" ", width-len([]rune(row)))
+	}
+
+	return vault, nil
+}
+
+func parseDepthRange(value string) (int, int, error) {
+	// Parse a depth range, such as "3-8", "3-" (3 or deeper), or "3" (only depth 3)
+	parts := strings.SplitN(value, "-", 2)
+
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(parts) == 1 {
+		return min, min, nil
+	}
+
+	if strings.TrimSpace(parts[1]) == "" {
+		return min, 0, nil
+	}
+
+	max, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	return min, max, err
+}
+
+func (v *Vault) Width() int {
+	width := 0
+	for _, row := range v.Rows {
+		if len([]rune(row)) > width {
+			width = len([]rune(row))
+		}
+	}
+	return width
+}
+
+func (v *Vault) Height() int {
+	return len(v.Rows)
+}
+
+func (v *Vault) AllowedAt(depth int) bool {
+	// A maximum depth of zero means there is no limit on how deep the vault can appear
+	return depth >= v.MinDepth && (v.MaxDepth == 0 || depth <= v.MaxDepth)
+}
+
+func (v *Vault) Transform(rotations int, mirror bool) *Vault {
+	// Return a copy of the vault, mirrored left to right (if asked), and then rotated clockwise the given number of
+	// quarter turns
+	grid := make([][]rune, len(v.Rows))
+	for i, row := range v.Rows {
+		grid[i] = []rune(row)
+	}
+
+	if mirror {
+		for _, row := range grid {
+			for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+				row[i], row[j] = row[j], row[i]
+			}
+		}
+	}
+
+	for r := 0; r < ((rotations%4)+4)%4; r++ {
+		height, width := len(grid), len(grid[0])
+		rotated := make([][]rune, width)
+
+		for y := 0; y < width; y++ {
+			rotated[y] = make([]rune, height)
+			for x := 0; x < height; x++ {
+				rotated[y][x] = grid[height-1-x][y]
+			}
+		}
+		grid = rotated
+	}
+
+	transformed := *v
+	transformed.Rows = make([]string, len(grid))
+	for i, row := range grid {
+		transformed.Rows[i] = string(row)
+	}
+
+	return &transformed
+}
+
+func ChooseVault(vaults []*Vault, depth int) *Vault {
+	// Pick a random vault that may appear at the given depth. Vaults with a higher weight are picked more often.
+	// Returns nil if there are none to pick from.
+	total := 0
+	for _, vault := range vaults {
+		if vault.AllowedAt(depth) && vault.Weight > 0 {
+			total += vault.Weight
+		}
+	}
+
+	if total == 0 {
+		return nil
+	}
+
+	roll := rand.Intn(total)
+	for _, vault := range vaults {
+		if !vault.AllowedAt(depth) || vault.Weight <= 0 {
+			continue
+		}
+
+		if roll < vault.Weight {
+			return vault
+		}
+		roll -= vault.Weight
+	}
+
+	return nil
+}
+
+func (m *Map) PlaceVault(vault *Vault, attempts int) ([]VaultSpawn, bool) {
+	// Try to stamp the vault, randomly rotated and mirrored, into an open area of the map. The whole area the vault
+	// covers must be open floor, with no stairs, and once it is in place every floor tile on the map must still be
+	// reachable. If it cannot be placed within the given number of attempts, the map is left as it was. Returns the
+	// things the vault wants spawned, and whether it was placed.
+	for i := 0; i < attempts; i++ {
+		placed := vault.Transform(rand.Intn(4), rand.Intn(2) == 0)
+		width, height := placed.Width(), placed.Height()
+
+		if width > m.Width-2 || height > m.Height-2 {
+			continue
+		}
+
+		left := 1 + rand.Intn(m.Width-1-width)
+		top := 1 + rand.Intn(m.Height-1-height)
+
+		if !m.areaIsOpen(left, top, width, height) {
+			continue
+		}
+
+		// Remember what was here, so it can be put back if the vault would cut the map in two
+		saved := make([]int, 0, width*height)
+		var spawns []VaultSpawn
+
+		for y, row := range placed.Rows {
+			for x, glyph := range []rune(row) {
+				tile := m.Tiles[left+x][top+y]
+				saved = append(saved, tile.Type)
+
+				switch glyph {
+				case ' ':
+				case '#':
+					tile.SetType(TileWall)
+				case '.':
+					tile.SetType(TileFloor)
+				default:
+					// The legend either names a type of tile, or something to spawn on the floor
+					name := placed.Legend[glyph]
+					if tileType, ok := TileTypeByName(name); ok {
+						tile.SetType(tileType)
+					} else {
+						tile.SetType(TileFloor)
+						spawns = append(spawns, VaultSpawn{X: left + x, Y: top + y, Name: name})
+					}
+				}
+			}
+		}
+
+		if len(m.findCaverns()) == 1 {
+			return spawns, true
+		}
+
+		j := 0
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				m.Tiles[left+x][top+y].SetType(saved[j])
+				j++
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func (m *Map) areaIsOpen(left, top, width, height int) bool {
+	for x := left; x < left+width; x++ {
+		for y := top; y < top+height; y++ {
+			if m.Tiles[x][y].IsWall() || m.Tiles[x][y].IsDoor() || m.Tiles[x][y].Stairs != NoStairs {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package gamemap
+
+// Visibility records which tiles of a map the player can currently see. It is kept apart from the tiles themselves, so
+// that it only has to be touched when what the player can see actually changes. It also remembers what could be seen
+// at the start of the current turn, so it can tell which tiles came into view, and which went out of view, this turn.
+type Visibility struct {
+	width   int
+	height  int
+	visible []bool
+	tiles   []*Tile
+
+	// Buffers swapped with the ones above on every update, so nothing needs allocating once the game is under way.
+	// spare is always left all false.
+	spare      []bool
+	spareTiles []*Tile
+
+	// What could be seen at the start of the turn
+	turnStart      []bool
+	turnStartTiles []*Tile
+}
+
+func NewVisibility(width, height int) *Visibility {
+	return &Visibility{width: width, height: height, visible: make([]bool, width*height),
+		spare: make([]bool, width*height), turnStart: make([]bool, width*height)}
+}
+
+func (v *Visibility) IsVisible(x, y int) bool {
+	if v == nil || x < 0 || y < 0 || x >= v.width || y >= v.height {
+		return false
+	}
+	return v.visible[x*v.height+y]
+}
+
+func (v *Visibility) Update(visible []*Tile) {
+	// Replace what can be seen with a new set of tiles. Every tile that comes into view is marked as explored.
+	next, tiles := v.spare, v.spareTiles[:0]
+
+	for _, tile := range visible {
+		i := tile.X*v.height + tile.Y
+		if next[i] {
+			continue
+		}
+
+		next[i] = true
+		tiles = append(tiles, tile)
+
+		if !v.visible[i] {
+			tile.Explored = true
+		}
+	}
+
+	// Only the tiles that were visible need clearing, to leave the old buffer all false, ready for the next update
+	for _, tile := range v.tiles {
+		v.visible[tile.X*v.height+tile.Y] = false
+	}
+
+	v.spare, v.spareTiles = v.visible, v.tiles
+	v.visible, v.tiles = next, tiles
+}
+
+func (v *Visibility) StartTurn() {
+	// Remember what can be seen right now, as the starting point for Shown and Hidden. This is called once at the start
+	// of each turn, so however many times the map is redrawn during a turn, they still report the changes made by the
+	// turn as a whole.
+	for _, tile := range v.turnStartTiles {
+		v.turnStart[tile.X*v.height+tile.Y] = false
+	}
+
+	for _, tile := range v.tiles {
+		v.turnStart[tile.X*v.height+tile.Y] = true
+	}
+
+	v.turnStartTiles = append(v.turnStartTiles[:0], v.tiles...)
+}
+
+func (v *Visibility) VisibleTiles() []*Tile {
+	return v.tiles
+}
+
+func (v *Visibility) Shown() []*Tile {
+	// Return every tile that came into view this turn
+	var shown []*Tile
+
+	for _, tile := range v.tiles {
+		if !v.turnStart[tile.X*v.height+tile.Y] {
+			shown = append(shown, tile)
+		}
+	}
+
+	return shown
+}
+
+func (v *Visibility) Hidden() []*Tile {
+	// Return every tile that went out of view this turn
+	var hidden []*Tile
+
+	for _, tile := range v.turnStartTiles {
+		if !v.visible[tile.X*v.height+tile.Y] {
+			hidden = append(hidden, tile)
+		}
+	}
+
+	return hidden
+}
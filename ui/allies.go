@@ -0,0 +1,30 @@
+package ui
+
+import (
+	blt "bearlibterminal"
+)
+
+func PrintAlliesHeader(viewAreaX, startY int) {
+	// Print the heading for the list of allies in the sidebar
+	blt.Print(viewAreaX, startY, "Allies:")
+}
+
+func PrintAlly(name, color string, hp, maxHp, viewAreaX, startY int) {
+	// Print an allies name, and a health bar underneath it
+	blt.Print(viewAreaX, startY, Colored(name, color))
+	printHpBar(hp, maxHp, viewAreaX, startY+1)
+}
+
+func DisplayOrdersMenu(allyCount int) {
+	blt.Print(1, 1, "Give orders to your allies")
+	blt.Print(1, 2, "--------------------")
+
+	if allyCount == 0 {
+		blt.Print(1, 3, "You have no allies...")
+		return
+	}
+
+	blt.Print(1, 3, "f - Follow me")
+	blt.Print(1, 4, "s - Stay here")
+	blt.Print(1, 5, "a - Attack a target")
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	blt "bearlibterminal"
+	"fmt"
+	"strconv"
+)
+
+func (ml *MessageLog) DisplayHistory(scroll int, filter string, searching bool, windowWidth, windowHeight int) int {
+	// Show a full screen list of past messages, oldest at the top, and newest at the bottom. Scroll is how many
+	// messages back from the newest the bottom of the list is. It is kept within bounds, and the value actually used is
+	// returned.
+	ClearScreen(windowWidth, windowHeight)
+
+	messages := ml.Messages(filter)
+
+	pageSize := windowHeight - 4
+	maxScroll := len(messages) - pageSize
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+
+	if scroll > maxScroll {
+		scroll = maxScroll
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+
+	blt.Layer(1)
+	blt.Color(blt.ColorFromName("white"))
+	blt.Print(1, 0, "Message history ("+strconv.Itoa(len(messages))+" messages)")
+	blt.Print(1, 1, "--------------------")
+
+	// List which categories of message are being shown, and the keys that toggle them
+	categories := ""
+	for i, name := range CategoryNames {
+		color := "dark gray"
+		if ml.CategoryShown(i) {
+			color = "white"
+		}
+		categories += Colored(strconv.Itoa(i+1)+" "+name, color) + "  "
+	}
+	blt.Print(windowWidth-50, 0, categories)
+
+	end := len(messages) - scroll
+	start := end - pageSize
+	if start < 0 {
+		start = 0
+	}
+
+	for i, message := range messages[start:end] {
+		blt.Print(1, 2+i, Colored(fmt.Sprintf("%6d", message.Turn), "gray")+"  "+message.Display())
+	}
+
+	if searching {
+		blt.Print(1, windowHeight-1, "Search: "+filter+"_   (enter to finish, esc to clear)")
+	} else if filter != "" {
+		blt.Print(1, windowHeight-1, "Showing messages containing \""+filter+"\"   (/ to search again, esc to close)")
+	} else {
+		blt.Print(1, windowHeight-1, "j/k or arrows to scroll, page up/down, home/end, / to search, 1-4 to show or hide, esc to close")
+	}
+
+	return scroll
+}
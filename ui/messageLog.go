@@ -2,46 +2,162 @@ package ui
 
 import (
 	blt "bearlibterminal"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
+// Matches the color markup used in messages, such as [color=red] and [/color]
+var markup = regexp.MustCompile(`\[/?color[^\]]*\]`)
+
+const (
+	// What a message is about. The player can choose to hide whole categories of messages.
+	CategorySystem = iota
+	CategoryCombat
+	CategoryItem
+	CategoryFlavor
+)
+
+// The names of each message category, as shown to the player
+var CategoryNames = []string{"System", "Combat", "Items", "Flavor"}
+
+const (
+	// How much a message matters to the player, which decides the color it is shown in
+	SeverityInfo = iota
+	SeverityGood
+	SeverityWarning
+	SeverityDanger
+)
+
+var severityColors = map[int]string{
+	SeverityInfo:    "white",
+	SeverityGood:    "light green",
+	SeverityWarning: "yellow",
+	SeverityDanger:  "light red",
+}
+
+// A Message is a single entry in the message log. The same message sent several times in a row is only kept once, with
+// Count saying how many times it was sent, and Turn when it was last sent.
+type Message struct {
+	Text     string
+	Turn     int
+	Category int
+	Severity int
+	Count    int
+}
+
+func (m Message) Display() string {
+	// Return the message as it should be printed, colored by its severity, and with a count if it was repeated
+	text := Colored(m.Text, severityColors[m.Severity])
+	if m.Count > 1 {
+		text += " x" + strconv.Itoa(m.Count)
+	}
+	return text
+}
+
 type MessageLog struct {
-	messages  []string
+	messages  []Message
 	MaxLength int
+	Turn      int
+	hidden    map[int]bool
 }
 
 func (ml *MessageLog) InitMessages() {
-	ml.messages = make([]string, ml.MaxLength)
+	ml.messages = make([]Message, 0, ml.MaxLength)
+	ml.hidden = map[int]bool{}
 }
 
-func (ml *MessageLog) SendMessage(message string) {
-	// Prepend the message onto the messageLog slice
+func (ml *MessageLog) Send(category, severity int, message string) {
+	// Prepend the message onto the messageLog slice, stamped with the current turn. If it is the same as the last
+	// message sent, the two are collapsed together instead.
+	if len(ml.messages) > 0 && ml.messages[0].Text == message && ml.messages[0].Category == category && ml.messages[0].Severity == severity {
+		ml.messages[0].Count++
+		ml.messages[0].Turn = ml.Turn
+		return
+	}
+
 	if len(ml.messages) >= ml.MaxLength {
 		// Throw away any messages that exceed our total queue size
 		ml.messages = ml.messages[:len(ml.messages)-1]
 	}
-	ml.messages = append([]string{message}, ml.messages...)
+	ml.messages = append([]Message{{Text: message, Turn: ml.Turn, Category: category, Severity: severity, Count: 1}}, ml.messages...)
+}
+
+func (ml *MessageLog) ShowCategory(category int, shown bool) {
+	// Choose whether messages in the given category are shown, in the message area and in the message history
+	if ml.hidden == nil {
+		ml.hidden = map[int]bool{}
+	}
+	ml.hidden[category] = !shown
+}
+
+func (ml *MessageLog) CategoryShown(category int) bool {
+	return !ml.hidden[category]
 }
 
 func (ml *MessageLog) PrintMessages(viewAreaY, windowSizeX, windowSizeY int) {
-	// Print the latest five messages from the messageLog. These will be printed in reverse order (newest at the top),
-	// to make it appear they are scrolling down the screen
+	// Print the latest five messages from the messageLog, leaving out any the player has chosen to hide. These will be
+	// printed in reverse order (newest at the top), to make it appear they are scrolling down the screen
 	clearMessages(viewAreaY, windowSizeX, windowSizeY, 1)
 
-	toShow := 0
+	var toShow []Message
 
-	if len(ml.messages) <= 5 {
-		// Just loop through the messageLog, printing them in reverse order
-		toShow = len(ml.messages)
-	} else {
-		// If we have more than 5 messages stored, just show the five most recent
-		toShow = 5
+	for _, message := range ml.messages {
+		if len(toShow) == 5 {
+			break
+		}
+		if ml.CategoryShown(message.Category) {
+			toShow = append(toShow, message)
+		}
 	}
 
 	blt.Color(blt.ColorFromName("white"))
 	blt.Layer(1)
-	for i := toShow; i > 0; i-- {
-		blt.Print(1, (viewAreaY-1)+i, ml.messages[i-1])
+	for i := len(toShow); i > 0; i-- {
+		blt.Print(1, (viewAreaY-1)+i, toShow[i-1].Display())
+	}
+}
+
+func (ml *MessageLog) Messages(filter string) []Message {
+	// Return every message in the log, oldest first, leaving out any category the player has chosen to hide. If a
+	// filter is given, only messages containing it (ignoring case, and any color markup) are returned.
+	var messages []Message
+	filter = strings.ToLower(filter)
+
+	for i := len(ml.messages) - 1; i >= 0; i-- {
+		if !ml.CategoryShown(ml.messages[i].Category) {
+			continue
+		}
+
+		if filter == "" || strings.Contains(strings.ToLower(PlainText(ml.messages[i].Text)), filter) {
+			messages = append(messages, ml.messages[i])
+		}
 	}
+
+	return messages
+}
+
+func (ml *MessageLog) WriteHistory(w io.Writer) error {
+	// Write out every message in the log as plain text, oldest first, one per line, with the turn it happened on.
+	// Everything is written, even categories the player has hidden.
+	for i := len(ml.messages) - 1; i >= 0; i-- {
+		if _, err := fmt.Fprintf(w, "%6d  %s\n", ml.messages[i].Turn, PlainText(ml.messages[i].Display())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Colored(text, color string) string {
+	// Wrap some text in the markup that prints it in the given color
+	return "[color=" + color + "]" + text + "[/color]"
+}
+
+func PlainText(message string) string {
+	// Strip any color markup out of a message
+	return markup.ReplaceAllString(message, "")
 }
 
 func clearMessages(viewAreaY, windowSizeX, windowSizeY, layer int) {
@@ -0,0 +1,163 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type sent struct {
+	turn     int
+	category int
+	severity int
+	text     string
+}
+
+func newLog(maxLength int) *MessageLog {
+	ml := &MessageLog{MaxLength: maxLength}
+	ml.InitMessages()
+	return ml
+}
+
+func TestMessageLogCoalescesRepeats(t *testing.T) {
+	tests := []struct {
+		name   string
+		sends  []sent
+		expect []Message
+	}{
+		{
+			name: "the same message twice in a row",
+			sends: []sent{
+				{1, CategoryCombat, SeverityInfo, "The orc hits you."},
+				{2, CategoryCombat, SeverityInfo, "The orc hits you."},
+			},
+			expect: []Message{{Text: "The orc hits you.", Turn: 2, Category: CategoryCombat, Severity: SeverityInfo, Count: 2}},
+		},
+		{
+			name: "a different message in between",
+			sends: []sent{
+				{1, CategoryCombat, SeverityInfo, "The orc hits you."},
+				{1, CategoryCombat, SeverityInfo, "You hit the orc."},
+				{2, CategoryCombat, SeverityInfo, "The orc hits you."},
+			},
+			expect: []Message{
+				{Text: "The orc hits you.", Turn: 1, Category: CategoryCombat, Severity: SeverityInfo, Count: 1},
+				{Text: "You hit the orc.", Turn: 1, Category: CategoryCombat, Severity: SeverityInfo, Count: 1},
+				{Text: "The orc hits you.", Turn: 2, Category: CategoryCombat, Severity: SeverityInfo, Count: 1},
+			},
+		},
+		{
+			name: "the same text at a different severity",
+			sends: []sent{
+				{1, CategoryCombat, SeverityInfo, "The orc hits you."},
+				{1, CategoryCombat, SeverityDanger, "The orc hits you."},
+			},
+			expect: []Message{
+				{Text: "The orc hits you.", Turn: 1, Category: CategoryCombat, Severity: SeverityInfo, Count: 1},
+				{Text: "The orc hits you.", Turn: 1, Category: CategoryCombat, Severity: SeverityDanger, Count: 1},
+			},
+		},
+		{
+			name: "the same text in a different category",
+			sends: []sent{
+				{1, CategoryCombat, SeverityInfo, "Something happens."},
+				{1, CategoryFlavor, SeverityInfo, "Something happens."},
+			},
+			expect: []Message{
+				{Text: "Something happens.", Turn: 1, Category: CategoryCombat, Severity: SeverityInfo, Count: 1},
+				{Text: "Something happens.", Turn: 1, Category: CategoryFlavor, Severity: SeverityInfo, Count: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ml := newLog(10)
+			for _, s := range test.sends {
+				ml.Turn = s.turn
+				ml.Send(s.category, s.severity, s.text)
+			}
+
+			messages := ml.Messages("")
+			if len(messages) != len(test.expect) {
+				t.Fatalf("expected %d messages, got %d: %v", len(test.expect), len(messages), messages)
+			}
+
+			for i := range messages {
+				if messages[i] != test.expect[i] {
+					t.Errorf("message %d: expected %+v, got %+v", i, test.expect[i], messages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMessageLogRepeatCount(t *testing.T) {
+	ml := newLog(10)
+	for i := 0; i < 3; i++ {
+		ml.Send(CategorySystem, SeverityInfo, "You wait.")
+	}
+
+	if display := PlainText(ml.Messages("")[0].Display()); display != "You wait. x3" {
+		t.Errorf("expected %q, got %q", "You wait. x3", display)
+	}
+}
+
+func TestMessageLogHidesCategories(t *testing.T) {
+	tests := []struct {
+		name   string
+		hidden []int
+		expect []string
+	}{
+		{"nothing hidden", nil, []string{"system", "combat", "item", "flavor"}},
+		{"combat hidden", []int{CategoryCombat}, []string{"system", "item", "flavor"}},
+		{"everything but items hidden", []int{CategorySystem, CategoryCombat, CategoryFlavor}, []string{"item"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ml := newLog(10)
+			ml.Send(CategorySystem, SeverityInfo, "system")
+			ml.Send(CategoryCombat, SeverityInfo, "combat")
+			ml.Send(CategoryItem, SeverityInfo, "item")
+			ml.Send(CategoryFlavor, SeverityInfo, "flavor")
+
+			for _, category := range test.hidden {
+				ml.ShowCategory(category, false)
+			}
+
+			var texts []string
+			for _, message := range ml.Messages("") {
+				texts = append(texts, message.Text)
+			}
+
+			if strings.Join(texts, ",") != strings.Join(test.expect, ",") {
+				t.Errorf("expected %v, got %v", test.expect, texts)
+			}
+
+			// The history file always has everything in it, whatever is hidden
+			var history bytes.Buffer
+			if err := ml.WriteHistory(&history); err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Count(history.String(), "\n"); lines != 4 {
+				t.Errorf("expected 4 lines of history, got %d", lines)
+			}
+		})
+	}
+}
+
+func TestMessageLogShowCategoryAgain(t *testing.T) {
+	ml := newLog(10)
+	ml.Send(CategoryFlavor, SeverityInfo, "flavor")
+
+	ml.ShowCategory(CategoryFlavor, false)
+	if len(ml.Messages("")) != 0 {
+		t.Error("expected the flavor message to be hidden")
+	}
+
+	ml.ShowCategory(CategoryFlavor, true)
+	if len(ml.Messages("")) != 1 {
+		t.Error("expected the flavor message to be shown again")
+	}
+}
@@ -56,3 +56,8 @@ func printHpBar(hp, maxHp, startX, startY int) {
 func round(f float64) float64 {
 	return math.Floor(f + .5)
 }
+
+func PrintDepth(depth, viewAreaX int) {
+	// Print how far down into the dungeon the player is
+	blt.Print(viewAreaX, 6, "Depth: "+strconv.Itoa(depth))
+}